@@ -0,0 +1,87 @@
+package compiler
+
+import "fmt"
+
+const (
+	OP_CONSTANT = iota
+	OP_NIL
+	OP_TRUE
+	OP_FALSE
+	OP_POP
+	OP_GET_LOCAL
+	OP_SET_LOCAL
+	OP_GET_GLOBAL
+	OP_DEFINE_GLOBAL
+	OP_SET_GLOBAL
+	OP_GET_UPVALUE
+	OP_SET_UPVALUE
+	OP_GET_PROPERTY
+	OP_SET_PROPERTY
+	OP_GET_SUPER
+	OP_EQUAL
+	OP_NOT_EQUAL
+	OP_GREATER
+	OP_GREATER_EQUAL
+	OP_LESS
+	OP_LESS_EQUAL
+	OP_ADD
+	OP_SUBTRACT
+	OP_MULTIPLY
+	OP_DIVIDE
+	OP_NOT
+	OP_NEGATE
+	OP_PRINT
+	OP_JUMP
+	OP_JUMP_IF_FALSE
+	OP_LOOP
+	OP_CALL
+	OP_CLOSURE
+	OP_CLOSE_UPVALUE
+	OP_RETURN
+	OP_CLASS
+	OP_INHERIT
+	OP_METHOD
+	OP_LIST
+	OP_GET_INDEX
+	OP_SET_INDEX
+	OP_MAP
+	OP_THROW
+	OP_TRY
+	OP_END_TRY
+	OP_STASH
+	OP_UNSTASH
+	OP_IMPORT
+	OP_INTERPOLATE
+)
+
+// Chunk is compiled bytecode. Each byte of Code was compiled from the
+// token on the matching entry of Lines, which covers the matching pair of
+// Columns: its first column and the one past its last.
+type Chunk struct {
+	Code      []byte
+	Lines     []int
+	Columns   [][2]int
+	Constants []interface{}
+}
+
+func (c *Chunk) write(b byte, line int, columns [2]int) {
+	c.Code = append(c.Code, b)
+	c.Lines = append(c.Lines, line)
+	c.Columns = append(c.Columns, columns)
+}
+
+func (c *Chunk) addConstant(value interface{}) int {
+	c.Constants = append(c.Constants, value)
+	return len(c.Constants) - 1
+}
+
+type Function struct {
+	Name     string
+	Arity    int
+	Upvalues int
+	Chunk    Chunk
+}
+
+func (f Function) String() string {
+	return fmt.Sprintf("<function %s>", f.Name)
+}
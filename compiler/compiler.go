@@ -0,0 +1,802 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/Shri333/golox/fault"
+	"github.com/Shri333/golox/parser"
+	"github.com/Shri333/golox/scanner"
+)
+
+const (
+	F_SCRIPT   = 0
+	F_FUNCTION = 1
+	F_METHOD   = 2
+	F_INIT     = 3
+	MAX_LOCALS = 256
+)
+
+// E_TOO_LARGE is the diagnostic code of code that exceeds a limit of the
+// bytecode format.
+const E_TOO_LARGE = "E401"
+
+type local struct {
+	name     string
+	depth    int
+	captured bool
+}
+
+type upvalue struct {
+	index int
+	local bool
+}
+
+type loop struct {
+	enclosing *loop
+	depth     int
+	guards    int
+	breaks    []int
+	continues []int
+}
+
+// guard tracks a try or catch body being compiled, so that statements
+// jumping out of it can remove its handler and run its finally block.
+type guard struct {
+	finally *parser.BlockStmt
+	handler bool
+	depth   int
+	loop    *loop
+}
+
+type state struct {
+	enclosing *state
+	function  *Function
+	ftype     int
+	locals    []local
+	upvalues  []upvalue
+	constants map[interface{}]int
+	depth     int
+	loop      *loop
+	guards    []*guard
+}
+
+type Compiler struct {
+	current  *state
+	line     int
+	columns  [2]int
+	file     string
+	reporter fault.Reporter
+}
+
+func NewCompiler(file string, reporter fault.Reporter) *Compiler {
+	return &Compiler{nil, 1, [2]int{}, file, reporter}
+}
+
+func (c *Compiler) Compile(stmts []parser.Stmt) (fn *Function, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if d, ok := r.(*fault.Diagnostic); ok {
+				c.reporter.Report(d)
+				err = d
+			} else {
+				panic(r)
+			}
+		}
+	}()
+
+	c.begin("", F_SCRIPT)
+	for _, stmt := range stmts {
+		stmt.Accept(c)
+	}
+
+	return c.end(), err
+}
+
+func (c *Compiler) VisitExprStmt(e *parser.ExprStmt) interface{} {
+	e.Expression.Accept(c)
+	c.emit(OP_POP)
+	return nil
+}
+
+func (c *Compiler) VisitPrintStmt(p *parser.PrintStmt) interface{} {
+	p.Expression.Accept(c)
+	c.emit(OP_PRINT)
+	return nil
+}
+
+func (c *Compiler) VisitVarStmt(v *parser.VarStmt) interface{} {
+	if v.Initializer != nil {
+		v.Initializer.Accept(c)
+	} else {
+		c.emit(OP_NIL)
+	}
+
+	c.defineVariable(v.Name)
+	return nil
+}
+
+func (c *Compiler) VisitBlockStmt(b *parser.BlockStmt) interface{} {
+	c.beginScope()
+	for _, stmt := range b.Statements {
+		stmt.Accept(c)
+	}
+	c.endScope()
+
+	return nil
+}
+
+func (c *Compiler) VisitIfStmt(i *parser.IfStmt) interface{} {
+	i.Condition.Accept(c)
+	thenJump := c.emitJump(OP_JUMP_IF_FALSE)
+	c.emit(OP_POP)
+	i.ThenBranch.Accept(c)
+
+	elseJump := c.emitJump(OP_JUMP)
+	c.patchJump(thenJump)
+	c.emit(OP_POP)
+	if i.ElseBranch != nil {
+		i.ElseBranch.Accept(c)
+	}
+	c.patchJump(elseJump)
+
+	return nil
+}
+
+func (c *Compiler) VisitWhileStmt(w *parser.WhileStmt) interface{} {
+	start := len(c.chunk().Code)
+	w.Condition.Accept(c)
+	exitJump := c.emitJump(OP_JUMP_IF_FALSE)
+	c.emit(OP_POP)
+
+	l := &loop{c.current.loop, c.current.depth, len(c.current.guards), nil, nil}
+	c.current.loop = l
+	w.Body.Accept(c)
+	c.current.loop = l.enclosing
+
+	for _, jump := range l.continues {
+		c.patchJump(jump)
+	}
+	if w.Increment != nil {
+		w.Increment.Accept(c)
+		c.emit(OP_POP)
+	}
+	c.emitLoop(start)
+
+	c.patchJump(exitJump)
+	c.emit(OP_POP)
+	for _, jump := range l.breaks {
+		c.patchJump(jump)
+	}
+
+	return nil
+}
+
+func (c *Compiler) VisitFunStmt(f *parser.FunStmt) interface{} {
+	if c.current.depth > 0 {
+		c.addLocal(f.Name)
+	}
+
+	c.function(f.Name, f.Params, f.Body, F_FUNCTION)
+	if c.current.depth == 0 {
+		c.emit(OP_DEFINE_GLOBAL)
+		c.emitShort(c.constant(f.Name.Lexeme))
+	}
+
+	return nil
+}
+
+func (c *Compiler) VisitReturnStmt(r *parser.ReturnStmt) interface{} {
+	c.at(r.Keyword)
+	if c.current.ftype == F_INIT {
+		c.emit(OP_GET_LOCAL, 0)
+	} else if r.Value != nil {
+		r.Value.Accept(c)
+	} else {
+		c.emit(OP_NIL)
+	}
+
+	if len(c.current.guards) > 0 {
+		c.emit(OP_STASH)
+		c.unwind(0)
+		c.at(r.Keyword)
+		c.emit(OP_UNSTASH)
+	}
+
+	c.emit(OP_RETURN)
+	return nil
+}
+
+func (c *Compiler) VisitClassStmt(s *parser.ClassStmt) interface{} {
+	scoped := c.current.depth > 0
+	c.at(s.Name)
+	c.emit(OP_CLASS)
+	c.emitShort(c.constant(s.Name.Lexeme))
+	c.defineVariable(s.Name)
+
+	if s.Super != nil {
+		s.Super.Accept(c)
+		c.beginScope()
+		c.current.locals = append(c.current.locals, local{"super", c.current.depth, false})
+		c.namedVariable(s.Name, scoped)
+		c.at(s.Super.Name)
+		c.emit(OP_INHERIT)
+		c.emitShort(c.constant(s.Super.Name.Lexeme))
+	}
+
+	c.namedVariable(s.Name, scoped)
+	for _, method := range s.Methods {
+		ftype := F_METHOD
+		if method.Name.Lexeme == "init" {
+			ftype = F_INIT
+		}
+		c.function(method.Name, method.Params, method.Body, ftype)
+		c.emit(OP_METHOD)
+		c.emitShort(c.constant(method.Name.Lexeme))
+	}
+	c.emit(OP_POP)
+
+	if s.Super != nil {
+		c.endScope()
+	}
+
+	return nil
+}
+
+func (c *Compiler) VisitBreakStmt(b *parser.BreakStmt) interface{} {
+	c.at(b.Keyword)
+	c.discardLocals(c.unwind(c.current.loop.guards), c.current.loop.depth)
+	c.current.loop.breaks = append(c.current.loop.breaks, c.emitJump(OP_JUMP))
+	return nil
+}
+
+func (c *Compiler) VisitContinueStmt(s *parser.ContinueStmt) interface{} {
+	c.at(s.Keyword)
+	c.discardLocals(c.unwind(c.current.loop.guards), c.current.loop.depth)
+	c.current.loop.continues = append(c.current.loop.continues, c.emitJump(OP_JUMP))
+	return nil
+}
+
+func (c *Compiler) VisitThrowStmt(t *parser.ThrowStmt) interface{} {
+	t.Value.Accept(c)
+	c.at(t.Keyword)
+	c.emit(OP_THROW)
+	return nil
+}
+
+func (c *Compiler) VisitTryStmt(t *parser.TryStmt) interface{} {
+	s := c.current
+	depth := s.depth
+	hidden := &scanner.Token{TokenType: scanner.IDENTIFIER, Line: t.Keyword.Line}
+	var exits []int
+
+	handler := c.emitTry(t.Keyword, t.Catch == nil)
+	s.guards = append(s.guards, &guard{t.Finally, true, depth, s.loop})
+	t.Body.Accept(c)
+	s.guards = s.guards[:len(s.guards)-1]
+	c.emit(OP_END_TRY)
+	if t.Finally != nil {
+		t.Finally.Accept(c)
+	}
+	exits = append(exits, c.emitJump(OP_JUMP))
+	c.patchJump(handler)
+
+	c.beginScope()
+	if t.Catch != nil {
+		c.addLocal(t.Name)
+		if t.Finally != nil {
+			handler = c.emitTry(t.Keyword, true)
+		}
+		s.guards = append(s.guards, &guard{t.Finally, t.Finally != nil, depth, s.loop})
+		for _, stmt := range t.Catch.Statements {
+			stmt.Accept(c)
+		}
+		s.guards = s.guards[:len(s.guards)-1]
+
+		if t.Finally == nil {
+			c.endScope()
+			c.patchJump(exits[0])
+			return nil
+		}
+
+		c.emit(OP_END_TRY)
+		c.endScope()
+		t.Finally.Accept(c)
+		exits = append(exits, c.emitJump(OP_JUMP))
+		c.patchJump(handler)
+
+		// the exception that entered the catch body is still on the stack
+		c.beginScope()
+		c.addLocal(hidden)
+	}
+
+	c.addLocal(hidden)
+	t.Finally.Accept(c)
+	c.at(t.Keyword)
+	c.emit(OP_GET_LOCAL, byte(len(s.locals)-1), OP_THROW)
+	s.depth = depth
+	s.locals = s.locals[:len(s.locals)-1]
+	if t.Catch != nil {
+		s.locals = s.locals[:len(s.locals)-1]
+	}
+
+	for _, exit := range exits {
+		c.patchJump(exit)
+	}
+
+	return nil
+}
+
+func (c *Compiler) VisitImportStmt(i *parser.ImportStmt) interface{} {
+	c.at(i.Keyword)
+	c.emit(OP_IMPORT)
+	c.emitShort(c.constant(i.Path.Literal.(string)))
+	c.defineVariable(i.Name)
+	return nil
+}
+
+func (c *Compiler) VisitBinaryExpr(b *parser.BinaryExpr) interface{} {
+	b.Left.Accept(c)
+	b.Right.Accept(c)
+	c.at(b.Operator)
+	switch b.Operator.TokenType {
+	case scanner.BANG_EQUAL:
+		c.emit(OP_NOT_EQUAL)
+	case scanner.EQUAL_EQUAL:
+		c.emit(OP_EQUAL)
+	case scanner.GREATER:
+		c.emit(OP_GREATER)
+	case scanner.GREATER_EQUAL:
+		c.emit(OP_GREATER_EQUAL)
+	case scanner.LESS:
+		c.emit(OP_LESS)
+	case scanner.LESS_EQUAL:
+		c.emit(OP_LESS_EQUAL)
+	case scanner.MINUS:
+		c.emit(OP_SUBTRACT)
+	case scanner.PLUS:
+		c.emit(OP_ADD)
+	case scanner.SLASH:
+		c.emit(OP_DIVIDE)
+	case scanner.STAR:
+		c.emit(OP_MULTIPLY)
+	}
+
+	return nil
+}
+
+func (c *Compiler) VisitGroupingExpr(g *parser.GroupingExpr) interface{} {
+	g.Expression.Accept(c)
+	return nil
+}
+
+func (c *Compiler) VisitLiteralExpr(l *parser.LiteralExpr) interface{} {
+	switch l.Value {
+	case nil:
+		c.emit(OP_NIL)
+	case true:
+		c.emit(OP_TRUE)
+	case false:
+		c.emit(OP_FALSE)
+	default:
+		c.emit(OP_CONSTANT)
+		c.emitShort(c.constant(l.Value))
+	}
+
+	return nil
+}
+
+func (c *Compiler) VisitUnaryExpr(u *parser.UnaryExpr) interface{} {
+	u.Right.Accept(c)
+	c.at(u.Operator)
+	if u.Operator.TokenType == scanner.MINUS {
+		c.emit(OP_NEGATE)
+	} else {
+		c.emit(OP_NOT)
+	}
+
+	return nil
+}
+
+func (c *Compiler) VisitVariableExpr(v *parser.VariableExpr) interface{} {
+	c.namedVariable(v.Name, v.Local != nil)
+	return nil
+}
+
+func (c *Compiler) VisitAssignExpr(a *parser.AssignExpr) interface{} {
+	a.Value.Accept(c)
+	c.at(a.Name)
+	if a.Local == nil {
+		c.emit(OP_SET_GLOBAL)
+		c.emitShort(c.constant(a.Name.Lexeme))
+	} else if slot := resolveLocal(c.current, a.Name.Lexeme); slot != -1 {
+		c.emit(OP_SET_LOCAL, byte(slot))
+	} else {
+		c.emit(OP_SET_UPVALUE, byte(c.resolveUpvalue(c.current, a.Name.Lexeme)))
+	}
+
+	return nil
+}
+
+func (c *Compiler) VisitLogicalExpr(l *parser.LogicalExpr) interface{} {
+	l.Left.Accept(c)
+	if l.Operator.TokenType == scanner.OR {
+		elseJump := c.emitJump(OP_JUMP_IF_FALSE)
+		endJump := c.emitJump(OP_JUMP)
+		c.patchJump(elseJump)
+		c.emit(OP_POP)
+		l.Right.Accept(c)
+		c.patchJump(endJump)
+	} else {
+		endJump := c.emitJump(OP_JUMP_IF_FALSE)
+		c.emit(OP_POP)
+		l.Right.Accept(c)
+		c.patchJump(endJump)
+	}
+
+	return nil
+}
+
+func (c *Compiler) VisitCallExpr(e *parser.CallExpr) interface{} {
+	e.Callee.Accept(c)
+	for _, arg := range e.Arguments {
+		arg.Accept(c)
+	}
+
+	c.at(&e.Paren)
+	c.emit(OP_CALL, byte(len(e.Arguments)))
+	return nil
+}
+
+func (c *Compiler) VisitGetExpr(g *parser.GetExpr) interface{} {
+	g.Object.Accept(c)
+	c.at(g.Name)
+	c.emit(OP_GET_PROPERTY)
+	c.emitShort(c.constant(g.Name.Lexeme))
+	return nil
+}
+
+func (c *Compiler) VisitSetExpr(s *parser.SetExpr) interface{} {
+	s.Object.Accept(c)
+	s.Value.Accept(c)
+	c.at(s.Name)
+	c.emit(OP_SET_PROPERTY)
+	c.emitShort(c.constant(s.Name.Lexeme))
+	return nil
+}
+
+func (c *Compiler) VisitThisExpr(t *parser.ThisExpr) interface{} {
+	c.namedVariable(t.Keyword, t.Local != nil)
+	return nil
+}
+
+func (c *Compiler) VisitSuperExpr(s *parser.SuperExpr) interface{} {
+	this := scanner.Token{TokenType: scanner.THIS, Lexeme: "this", Line: s.Keyword.Line}
+	c.namedVariable(&this, true)
+	c.namedVariable(s.Keyword, true)
+	c.at(s.Method)
+	c.emit(OP_GET_SUPER)
+	c.emitShort(c.constant(s.Method.Lexeme))
+	return nil
+}
+
+func (c *Compiler) VisitFunctionExpr(f *parser.FunctionExpr) interface{} {
+	name := scanner.Token{TokenType: scanner.IDENTIFIER, Lexeme: fmt.Sprintf("anonymous@%d", f.Keyword.Line), Line: f.Keyword.Line}
+	c.function(&name, f.Params, f.Body, F_FUNCTION)
+	return nil
+}
+
+func (c *Compiler) VisitListExpr(l *parser.ListExpr) interface{} {
+	for _, element := range l.Elements {
+		element.Accept(c)
+	}
+
+	c.at(l.Bracket)
+	if len(l.Elements) > 0xffff {
+		panic(c.limit("too many elements in list literal"))
+	}
+
+	c.emit(OP_LIST)
+	c.emitShort(len(l.Elements))
+	return nil
+}
+
+func (c *Compiler) VisitIndexGetExpr(i *parser.IndexGetExpr) interface{} {
+	i.Object.Accept(c)
+	i.Index.Accept(c)
+	c.at(i.Bracket)
+	c.emit(OP_GET_INDEX)
+	return nil
+}
+
+func (c *Compiler) VisitIndexSetExpr(i *parser.IndexSetExpr) interface{} {
+	i.Object.Accept(c)
+	i.Index.Accept(c)
+	i.Value.Accept(c)
+	c.at(i.Bracket)
+	c.emit(OP_SET_INDEX)
+	return nil
+}
+
+func (c *Compiler) VisitMapExpr(m *parser.MapExpr) interface{} {
+	for i := range m.Keys {
+		m.Keys[i].Accept(c)
+		m.Values[i].Accept(c)
+	}
+
+	c.at(m.Brace)
+	if len(m.Keys) > 0xffff {
+		panic(c.limit("too many entries in map literal"))
+	}
+
+	c.emit(OP_MAP)
+	c.emitShort(len(m.Keys))
+	return nil
+}
+
+func (c *Compiler) VisitInterpolationExpr(i *parser.InterpolationExpr) interface{} {
+	for _, part := range i.Parts {
+		part.Accept(c)
+	}
+
+	if len(i.Parts) > 0xffff {
+		panic(c.limit("too many parts in interpolated string"))
+	}
+
+	c.emit(OP_INTERPOLATE)
+	c.emitShort(len(i.Parts))
+	return nil
+}
+
+func (c *Compiler) function(name *scanner.Token, params []*scanner.Token, body *parser.BlockStmt, ftype int) {
+	c.begin(name.Lexeme, ftype)
+	c.beginScope()
+	c.current.function.Arity = len(params)
+	for _, param := range params {
+		c.addLocal(param)
+	}
+
+	for _, stmt := range body.Statements {
+		stmt.Accept(c)
+	}
+
+	upvalues := c.current.upvalues
+	fn := c.end()
+	c.at(name)
+	c.emit(OP_CLOSURE)
+	c.emitShort(c.constant(fn))
+	for _, upvalue := range upvalues {
+		if upvalue.local {
+			c.emit(1, byte(upvalue.index))
+		} else {
+			c.emit(0, byte(upvalue.index))
+		}
+	}
+}
+
+func (c *Compiler) begin(name string, ftype int) {
+	s := &state{c.current, &Function{Name: name}, ftype, make([]local, 1, 8), nil, make(map[interface{}]int), 0, nil, nil}
+	if ftype == F_METHOD || ftype == F_INIT {
+		s.locals[0].name = "this"
+	}
+	c.current = s
+}
+
+func (c *Compiler) end() *Function {
+	// a script hands back slot zero, which holds the module when imported
+	if c.current.ftype == F_INIT || c.current.ftype == F_SCRIPT {
+		c.emit(OP_GET_LOCAL, 0)
+	} else {
+		c.emit(OP_NIL)
+	}
+	c.emit(OP_RETURN)
+
+	fn := c.current.function
+	fn.Upvalues = len(c.current.upvalues)
+	c.current = c.current.enclosing
+	return fn
+}
+
+func (c *Compiler) beginScope() {
+	c.current.depth++
+}
+
+func (c *Compiler) endScope() {
+	s := c.current
+	s.depth--
+	s.locals = s.locals[:c.discardLocals(len(s.locals), s.depth)]
+}
+
+// discardLocals pops the locals below index top that are deeper than depth
+// and returns how many locals remain.
+func (c *Compiler) discardLocals(top int, depth int) int {
+	s := c.current
+	for ; top > 0 && s.locals[top-1].depth > depth; top-- {
+		if s.locals[top-1].captured {
+			c.emit(OP_CLOSE_UPVALUE)
+		} else {
+			c.emit(OP_POP)
+		}
+	}
+
+	return top
+}
+
+// unwind leaves every guard from index first outwards, the way a jump out
+// of them would, and returns how many locals remain on the stack.
+func (c *Compiler) unwind(first int) int {
+	s := c.current
+	top := len(s.locals)
+	for i := len(s.guards) - 1; i >= first; i-- {
+		g := s.guards[i]
+		top = c.discardLocals(top, g.depth)
+		if g.handler {
+			c.emit(OP_END_TRY)
+		}
+		if g.finally == nil {
+			continue
+		}
+
+		// compile the finally block as if it stood where the try does
+		locals, guards, depth, loop := s.locals, s.guards, s.depth, s.loop
+		s.locals = append([]local(nil), locals[:top]...)
+		s.guards = append([]*guard(nil), guards[:i]...)
+		s.depth, s.loop = g.depth, g.loop
+		g.finally.Accept(c)
+		for j := range locals[:top] {
+			locals[j].captured = locals[j].captured || s.locals[j].captured
+		}
+		s.locals, s.guards, s.depth, s.loop = locals, guards, depth, loop
+	}
+
+	return top
+}
+
+func (c *Compiler) defineVariable(name *scanner.Token) {
+	if c.current.depth > 0 {
+		c.addLocal(name)
+		return
+	}
+
+	c.at(name)
+	c.emit(OP_DEFINE_GLOBAL)
+	c.emitShort(c.constant(name.Lexeme))
+}
+
+func (c *Compiler) addLocal(name *scanner.Token) {
+	if len(c.current.locals) == MAX_LOCALS {
+		c.at(name)
+		panic(c.limit("too many local variables in function"))
+	}
+
+	c.current.locals = append(c.current.locals, local{name.Lexeme, c.current.depth, false})
+}
+
+func (c *Compiler) namedVariable(name *scanner.Token, local bool) {
+	c.at(name)
+	if !local {
+		c.emit(OP_GET_GLOBAL)
+		c.emitShort(c.constant(name.Lexeme))
+	} else if slot := resolveLocal(c.current, name.Lexeme); slot != -1 {
+		c.emit(OP_GET_LOCAL, byte(slot))
+	} else {
+		c.emit(OP_GET_UPVALUE, byte(c.resolveUpvalue(c.current, name.Lexeme)))
+	}
+}
+
+func resolveLocal(s *state, name string) int {
+	for i := len(s.locals) - 1; i >= 0; i-- {
+		if s.locals[i].name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (c *Compiler) resolveUpvalue(s *state, name string) int {
+	if s.enclosing == nil {
+		panic(fmt.Sprintf("compiler: unresolved local variable '%s'", name))
+	}
+
+	if slot := resolveLocal(s.enclosing, name); slot != -1 {
+		s.enclosing.locals[slot].captured = true
+		return c.addUpvalue(s, slot, true)
+	}
+
+	return c.addUpvalue(s, c.resolveUpvalue(s.enclosing, name), false)
+}
+
+func (c *Compiler) addUpvalue(s *state, index int, local bool) int {
+	for i, upvalue := range s.upvalues {
+		if upvalue.index == index && upvalue.local == local {
+			return i
+		}
+	}
+
+	if len(s.upvalues) == MAX_LOCALS {
+		panic(c.limit("too many closure variables in function"))
+	}
+
+	s.upvalues = append(s.upvalues, upvalue{index, local})
+	return len(s.upvalues) - 1
+}
+
+func (c *Compiler) chunk() *Chunk {
+	return &c.current.function.Chunk
+}
+
+func (c *Compiler) emit(bytes ...byte) {
+	for _, b := range bytes {
+		c.chunk().write(b, c.line, c.columns)
+	}
+}
+
+// at attributes the code emitted next to t. A token spanning several lines
+// is pointed at by its first column only.
+func (c *Compiler) at(t *scanner.Token) {
+	span := t.Span()
+	if span.EndLine != span.Line {
+		span.EndColumn = span.Column
+	}
+
+	c.line, c.columns = span.Line, [2]int{span.Column, span.EndColumn}
+}
+
+func (c *Compiler) limit(message string) *fault.Diagnostic {
+	return fault.NewDiagnostic(E_TOO_LARGE, c.file, c.line, c.columns[0], c.columns[1], message)
+}
+
+func (c *Compiler) emitShort(value int) {
+	c.emit(byte(value>>8), byte(value))
+}
+
+func (c *Compiler) emitJump(op byte) int {
+	c.emit(op, 0xff, 0xff)
+	return len(c.chunk().Code) - 2
+}
+
+func (c *Compiler) patchJump(offset int) {
+	jump := len(c.chunk().Code) - offset - 2
+	if jump > 0xffff {
+		panic(c.limit("too much code to jump over"))
+	}
+
+	c.chunk().Code[offset] = byte(jump >> 8)
+	c.chunk().Code[offset+1] = byte(jump)
+}
+
+func (c *Compiler) emitTry(keyword *scanner.Token, raw bool) int {
+	c.at(keyword)
+	handler := c.emitJump(OP_TRY)
+	if raw {
+		c.emit(1)
+	} else {
+		c.emit(0)
+	}
+
+	return handler
+}
+
+func (c *Compiler) emitLoop(start int) {
+	c.emit(OP_LOOP)
+	offset := len(c.chunk().Code) - start + 2
+	if offset > 0xffff {
+		panic(c.limit("loop body too large"))
+	}
+
+	c.emitShort(offset)
+}
+
+func (c *Compiler) constant(value interface{}) int {
+	if index, ok := c.current.constants[value]; ok {
+		return index
+	}
+
+	index := c.chunk().addConstant(value)
+	if index > 0xffff {
+		panic(c.limit("too many constants in one chunk"))
+	}
+
+	c.current.constants[value] = index
+	return index
+}
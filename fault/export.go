@@ -0,0 +1,156 @@
+package fault
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"sort"
+)
+
+// entry is an error in the form it is exported in.
+type entry struct {
+	Severity  string  `json:"severity"`
+	Code      string  `json:"code,omitempty"`
+	File      string  `json:"file,omitempty"`
+	Line      int     `json:"line,omitempty"`
+	Column    int     `json:"column,omitempty"`
+	EndColumn int     `json:"endColumn,omitempty"`
+	Message   string  `json:"message"`
+	Trace     []Frame `json:"trace,omitempty"`
+}
+
+func entries(err error) []entry {
+	switch e := err.(type) {
+	case *Fault:
+		return []entry{{SEV_ERROR.String(), E_RUNTIME, display(e.file), e.line, e.column, e.end, e.message, e.trace}}
+	case *Diagnostic:
+		return []entry{{e.Severity.String(), e.Code, display(e.File), e.Line, e.Column, e.EndColumn, e.Message, nil}}
+	case Diagnostics:
+		var all []entry
+		for _, d := range e {
+			all = append(all, entries(d)...)
+		}
+		return all
+	}
+
+	return []entry{{SEV_ERROR.String(), "", "", 0, 0, 0, err.Error(), nil}}
+}
+
+type jsonLines struct {
+	encoder *json.Encoder
+}
+
+// NewJSONReporter returns a Reporter that writes each error to w as a JSON
+// object on its own line, with the stack trace of runtime errors.
+func NewJSONReporter(w io.Writer) Reporter {
+	return &jsonLines{json.NewEncoder(w)}
+}
+
+func (j *jsonLines) Report(err error) {
+	for _, e := range entries(err) {
+		j.encoder.Encode(e)
+	}
+}
+
+// SARIF is a Reporter that collects errors into a SARIF 2.1.0 log, which
+// Flush writes out once the run is over.
+type SARIF struct {
+	w       io.Writer
+	entries []entry
+}
+
+func NewSARIF(w io.Writer) *SARIF {
+	return &SARIF{w, nil}
+}
+
+func (s *SARIF) Report(err error) {
+	s.entries = append(s.entries, entries(err)...)
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules,omitempty"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId,omitempty"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysical `json:"physicalLocation"`
+}
+
+type sarifPhysical struct {
+	ArtifactLocation sarifArtifact `json:"artifactLocation"`
+	Region           *sarifRegion  `json:"region,omitempty"`
+}
+
+type sarifArtifact struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// Flush writes the log of every error reported so far. Columns count
+// Unicode code points, as they do everywhere else.
+func (s *SARIF) Flush() error {
+	codes := make(map[string]bool)
+	results := make([]sarifResult, 0, len(s.entries))
+	for _, e := range s.entries {
+		result := sarifResult{e.Code, e.Severity, sarifMessage{e.Message}, nil}
+		if e.File != "" {
+			var region *sarifRegion
+			if e.Line > 0 {
+				region = &sarifRegion{e.Line, e.Column, e.EndColumn}
+			}
+			result.Locations = []sarifLocation{{sarifPhysical{sarifArtifact{filepath.ToSlash(e.File)}, region}}}
+		}
+		if e.Code != "" {
+			codes[e.Code] = true
+		}
+		results = append(results, result)
+	}
+
+	rules := make([]sarifRule, 0, len(codes))
+	for code := range codes {
+		rules = append(rules, sarifRule{code})
+	}
+	sort.Slice(rules, func(a, b int) bool { return rules[a].ID < rules[b].ID })
+
+	run := sarifRun{sarifTool{sarifDriver{"golox", rules}}, "unicodeCodePoints", results}
+	log := sarifLog{"https://json.schemastore.org/sarif-2.1.0.json", "2.1.0", []sarifRun{run}}
+	encoder := json.NewEncoder(s.w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(log)
+}
@@ -3,22 +3,181 @@ package fault
 import (
 	"fmt"
 	"io"
-	"os"
+	"strings"
 )
 
-var W io.Writer = os.Stdout
-
+// Fault is a runtime error. Its columns are zero when the source it comes
+// from is not known more precisely than a line, and its file is empty until
+// the error reaches the top of a run.
 type Fault struct {
 	line    int
+	column  int
+	end     int
+	file    string
 	message string
+	trace   []Frame
+}
+
+// Frame is one call on the Lox stack when a fault was raised, innermost
+// first, with the line execution had reached in that function.
+type Frame struct {
+	Function string `json:"function"`
+	Line     int    `json:"line"`
 }
 
+// E_RUNTIME is the diagnostic code of every Fault when it is exported.
+const E_RUNTIME = "E301"
+
 func (f *Fault) Error() string {
 	return fmt.Sprintf("Error (line %d): %s", f.line, f.message)
 }
 
-func NewFault(line int, message string) *Fault {
-	fault := &Fault{line, message}
-	fmt.Fprintln(W, fault)
-	return fault
+func (f *Fault) Line() int {
+	return f.line
+}
+
+func (f *Fault) Message() string {
+	return f.message
+}
+
+// Columns returns the first column the fault covers and the one past its
+// last.
+func (f *Fault) Columns() (int, int) {
+	return f.column, f.end
+}
+
+func (f *Fault) File() string {
+	return f.file
+}
+
+func (f *Fault) SetFile(file string) {
+	f.file = file
+}
+
+func (f *Fault) Trace() []Frame {
+	return f.trace
+}
+
+func (f *Fault) SetTrace(trace []Frame) {
+	f.trace = trace
+}
+
+// traceEnds is how many frames are printed from each end of a long trace.
+const traceEnds = 10
+
+// Traceback renders the fault followed by its stack trace, if it was raised
+// inside a function.
+func (f *Fault) Traceback() string {
+	return strings.Join(append([]string{f.Error()}, f.frames()...), "\n")
+}
+
+// frames renders the stack trace one frame per line, or nothing when the
+// fault was raised at the top level.
+func (f *Fault) frames() []string {
+	if len(f.trace) < 2 {
+		return nil
+	}
+
+	var lines []string
+	for j, frame := range f.trace {
+		if len(f.trace) > 2*traceEnds && j == traceEnds {
+			lines = append(lines, fmt.Sprintf("    ... %d more frames", len(f.trace)-2*traceEnds))
+		}
+		if len(f.trace) > 2*traceEnds && j >= traceEnds && j < len(f.trace)-traceEnds {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("    at %s (line %d)", frame.Function, frame.Line))
+	}
+
+	return lines
+}
+
+func New(line int, message string) *Fault {
+	return &Fault{line, 0, 0, "", message, nil}
+}
+
+func NewAt(line int, column int, end int, message string) *Fault {
+	return &Fault{line, column, end, "", message, nil}
+}
+
+type Severity int
+
+const (
+	SEV_ERROR Severity = iota
+	SEV_WARNING
+)
+
+func (s Severity) String() string {
+	if s == SEV_WARNING {
+		return "warning"
+	}
+
+	return "error"
+}
+
+// Diagnostic is a problem found in a source file before it runs. It covers
+// columns Column up to but not including EndColumn of Line, and Code
+// identifies the kind of problem independently of the wording of Message.
+type Diagnostic struct {
+	Severity  Severity
+	Code      string
+	File      string
+	Line      int
+	Column    int
+	EndColumn int
+	Message   string
+}
+
+func NewDiagnostic(code string, file string, line int, column int, end int, message string) *Diagnostic {
+	return &Diagnostic{SEV_ERROR, code, file, line, column, end, message}
+}
+
+func (d *Diagnostic) Error() string {
+	if d.Severity == SEV_WARNING {
+		return fmt.Sprintf("Warning (line %d): %s", d.Line, d.Message)
+	}
+
+	return fmt.Sprintf("Error (line %d): %s", d.Line, d.Message)
+}
+
+// Diagnostics collects every diagnostic found while scanning or parsing a
+// source, one per line when printed.
+type Diagnostics []*Diagnostic
+
+func (d Diagnostics) Error() string {
+	lines := make([]string, len(d))
+	for i, diagnostic := range d {
+		lines[i] = diagnostic.Error()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Reporter receives the errors of a run as they are found: syntax and
+// resolution errors as well as uncaught runtime errors.
+type Reporter interface {
+	Report(err error)
+}
+
+type printer struct {
+	w io.Writer
+}
+
+// NewReporter returns a Reporter that prints each error to w on its own
+// line, followed by the stack trace of runtime errors.
+func NewReporter(w io.Writer) Reporter {
+	return &printer{w}
+}
+
+func (p *printer) Report(err error) {
+	switch e := err.(type) {
+	case *Fault:
+		fmt.Fprintln(p.w, e.Traceback())
+	case Diagnostics:
+		for _, d := range e {
+			p.Report(d)
+		}
+	default:
+		fmt.Fprintln(p.w, err)
+	}
 }
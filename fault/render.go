@@ -0,0 +1,164 @@
+package fault
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	COLOR_RESET   = "\x1b[0m"
+	COLOR_ERROR   = "\x1b[1;31m"
+	COLOR_WARNING = "\x1b[1;33m"
+	COLOR_GUTTER  = "\x1b[1;34m"
+)
+
+// Renderer is a Reporter that prints each error with the line of source it
+// points at, underlining the exact text when its columns are known. Sources
+// are read from disk by file name unless they were given to Source first.
+type Renderer struct {
+	w       io.Writer
+	color   bool
+	sources map[string][]string
+}
+
+// NewRenderer returns a Renderer printing to w, with ANSI colours if color
+// is set.
+func NewRenderer(w io.Writer, color bool) *Renderer {
+	return &Renderer{w, color, make(map[string][]string)}
+}
+
+// Source sets the text errors in file are rendered against. The REPL sets
+// everything entered in the session as the source of file "".
+func (r *Renderer) Source(file string, text string) {
+	r.sources[file] = strings.Split(text, "\n")
+}
+
+func (r *Renderer) Report(err error) {
+	switch e := err.(type) {
+	case *Fault:
+		r.print(COLOR_ERROR, e.Error(), e.file, e.line, e.column, e.end)
+		for _, frame := range e.frames() {
+			fmt.Fprintln(r.w, frame)
+		}
+	case *Diagnostic:
+		color := COLOR_ERROR
+		if e.Severity == SEV_WARNING {
+			color = COLOR_WARNING
+		}
+		r.print(color, e.Error(), e.File, e.Line, e.Column, e.EndColumn)
+	case Diagnostics:
+		for _, d := range e {
+			r.Report(d)
+		}
+	default:
+		fmt.Fprintln(r.w, r.paint(COLOR_ERROR, err.Error()))
+	}
+}
+
+// print writes the header of an error followed by an excerpt like
+//
+//	 --> file.lox:3:7
+//	  |
+//	3 | print a + "b";
+//	  |       ^^^^^^^
+//
+// where the location line is left out for source that has no file.
+func (r *Renderer) print(color string, header string, file string, line int, column int, end int) {
+	fmt.Fprintln(r.w, r.paint(color, header))
+	source, ok := r.line(file, line)
+	if !ok {
+		return
+	}
+
+	width := len(fmt.Sprint(line))
+	if file != "" {
+		location := fmt.Sprintf("%s:%d", display(file), line)
+		if column > 0 {
+			location += fmt.Sprintf(":%d", column)
+		}
+		fmt.Fprintf(r.w, "%s %s\n", r.paint(COLOR_GUTTER, strings.Repeat(" ", width)+"-->"), location)
+	}
+
+	gutter := strings.Repeat(" ", width+1) + "|"
+	fmt.Fprintln(r.w, r.paint(COLOR_GUTTER, gutter))
+	fmt.Fprintf(r.w, "%s %s\n", r.paint(COLOR_GUTTER, fmt.Sprintf("%d |", line)), source)
+	if column > 0 {
+		fmt.Fprintf(r.w, "%s %s\n", r.paint(COLOR_GUTTER, gutter), r.paint(color, underline(source, column, end)))
+	}
+}
+
+// line returns the text of a line of file, counting from 1.
+func (r *Renderer) line(file string, line int) (string, bool) {
+	lines, ok := r.sources[file]
+	if !ok && file != "" {
+		if bytes, err := os.ReadFile(file); err == nil {
+			lines = strings.Split(string(bytes), "\n")
+		}
+		r.sources[file] = lines
+	}
+
+	if line < 1 || line > len(lines) {
+		return "", false
+	}
+
+	return strings.TrimRight(lines[line-1], "\r"), true
+}
+
+func (r *Renderer) paint(color string, text string) string {
+	if !r.color {
+		return text
+	}
+
+	return color + text + COLOR_RESET
+}
+
+// underline returns carets under columns column up to end of source, keeping
+// any tabs before them so that they line up.
+func underline(source string, column int, end int) string {
+	var pad strings.Builder
+	n := 0
+	for _, c := range source {
+		if n++; n >= column {
+			break
+		}
+		if c == '\t' {
+			pad.WriteRune('\t')
+		} else {
+			pad.WriteRune(' ')
+		}
+	}
+
+	width := end - column
+	if rest := utf8.RuneCountInString(source) - column + 1; width > rest {
+		width = rest
+	}
+	if width < 1 {
+		width = 1
+	}
+
+	return pad.String() + strings.Repeat("^", width)
+}
+
+// display shortens file to a path relative to the working directory when
+// it lies below it.
+func display(file string) string {
+	if !filepath.IsAbs(file) {
+		return file
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return file
+	}
+
+	rel, err := filepath.Rel(wd, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return file
+	}
+
+	return rel
+}
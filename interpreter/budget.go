@@ -0,0 +1,86 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrStepLimit is the cause of an Aborted error when a script takes more
+// steps than SetStepLimit allows.
+var ErrStepLimit = errors.New("step limit exceeded")
+
+// Aborted is returned when a script is stopped from outside by its context,
+// timeout or step limit. Lox code cannot catch it.
+type Aborted struct {
+	Err error
+}
+
+func (a *Aborted) Error() string {
+	return "execution aborted: " + a.Err.Error()
+}
+
+func (a *Aborted) Unwrap() error {
+	return a.Err
+}
+
+// budget bounds a single run: each loop iteration and call is one step.
+// Runs started by natives through Call are nested in the run of the script
+// calling them and share its budget.
+type budget struct {
+	ctx     context.Context
+	timeout time.Duration
+	limit   int
+	run     context.Context
+	steps   int
+	nesting int
+}
+
+// SetContext stops scripts once ctx is done.
+func (i *Interpreter) SetContext(ctx context.Context) {
+	i.budget.ctx = ctx
+}
+
+// SetTimeout stops each run (Interpret, Evaluate or Call) after d. Zero
+// means no timeout.
+func (i *Interpreter) SetTimeout(d time.Duration) {
+	i.budget.timeout = d
+}
+
+// SetStepLimit stops each run after n steps, where every loop iteration and
+// call is a step. Zero means no limit.
+func (i *Interpreter) SetStepLimit(n int) {
+	i.budget.limit = n
+}
+
+// start begins a run, or a nested one, and returns the function ending it.
+func (i *Interpreter) start() context.CancelFunc {
+	b := &i.budget
+	b.nesting++
+	if b.nesting > 1 {
+		return func() { b.nesting-- }
+	}
+
+	b.run, b.steps = b.ctx, 0
+	if b.timeout > 0 {
+		var cancel context.CancelFunc
+		b.run, cancel = context.WithTimeout(b.ctx, b.timeout)
+		return func() { b.nesting--; cancel() }
+	}
+
+	return func() { b.nesting-- }
+}
+
+func (i *Interpreter) step() {
+	b := &i.budget
+	b.steps++
+	if b.limit > 0 && b.steps > b.limit {
+		panic(&Aborted{ErrStepLimit})
+	}
+
+	select {
+	case <-b.run.Done():
+		panic(&Aborted{b.run.Err()})
+	default:
+	}
+}
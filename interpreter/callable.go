@@ -5,93 +5,105 @@ import (
 	"time"
 
 	"github.com/Shri333/golox/parser"
+	"github.com/Shri333/golox/scanner"
 )
 
+// callable takes between min and max arguments, or any number from min
+// when max is Variadic.
 type callable interface {
-	arity() int
+	arity() (min int, max int)
 	call(i *Interpreter, args []interface{}) interface{}
 }
 
-type clock struct{}
+type native struct {
+	name string
+	min  int
+	max  int
+	fn   func(i *Interpreter, args []interface{}) interface{}
+}
 
-func (c *clock) arity() int { return 0 }
+func (n *native) arity() (int, int) { return n.min, n.max }
 
-func (c *clock) call(i *Interpreter, args []interface{}) interface{} {
-	return float64(time.Now().UnixMilli() / 1000)
+func (n *native) call(i *Interpreter, args []interface{}) interface{} {
+	return n.fn(i, args)
+}
+
+func (n native) String() string {
+	return fmt.Sprintf("<native function %s>", n.name)
 }
 
-func (c clock) String() string {
-	return "<native function clock>"
+func clock(i *Interpreter, args []interface{}) interface{} {
+	return float64(time.Now().UnixMilli() / 1000)
 }
 
 type function struct {
-	declaration *parser.FunStmt
-	closure     *environment
-	init        bool
+	name    string
+	params  []*scanner.Token
+	body    *parser.BlockStmt
+	closure *environment
+	module  *module
+	init    bool
 }
 
-func (f *function) arity() int { return len(f.declaration.Params) }
+func (f *function) arity() (int, int) { return len(f.params), len(f.params) }
 
-func (f *function) call(i *Interpreter, args []interface{}) (value interface{}) {
-	env := &environment{f.closure, make(map[string]interface{})}
-	for i := 0; i < f.arity(); i++ {
-		env.define(f.declaration.Params[i].Lexeme, args[i])
+func (f *function) call(i *Interpreter, args []interface{}) interface{} {
+	i.enter(f.name, f.module.path, i.call)
+	if f.module != i.module {
+		prev := i.module
+		i.module = f.module
+		defer func() { i.module = prev }()
 	}
 
-	prev := i.current
-	defer func() {
-		i.current = prev
-		r := recover()
-		if err, ok := r.(error); ok {
-			panic(err)
-		} else {
-			if f.init {
-				value = f.closure.getAt("this", 0)
-			} else {
-				value = r
-			}
-		}
-	}()
-
-	i.current = env
-	for _, stmt := range f.declaration.Body.Statements {
-		stmt.Accept(i)
+	s := i.executeBlock(f.body.Statements, &environment{f.closure, args})
+	i.frames = i.frames[:len(i.frames)-1]
+	if f.init {
+		return f.closure.values[0]
 	}
 
-	if f.init {
-		return f.closure.getAt("this", 0)
+	if s == S_RETURN {
+		value := i.returned
+		i.returned = nil
+		return value
 	}
 
-	return value
+	return nil
 }
 
-func (f *function) bind(i *instance) *function {
-	env := &environment{f.closure, make(map[string]interface{})}
-	env.define("this", i)
-	return &function{f.declaration, env, f.init}
+func (f *function) bind(i *instance) callable {
+	env := &environment{f.closure, []interface{}{i}}
+	return &function{f.name, f.params, f.body, env, f.module, f.init}
 }
 
 func (f function) String() string {
-	return fmt.Sprintf("<function %s>", f.declaration.Name.Lexeme)
+	return fmt.Sprintf("<function %s>", f.name)
+}
+
+// method is what a class holds under a method name: a Lox function or a
+// method implemented in Go, either of which is bound to an instance.
+type method interface {
+	arity() (min int, max int)
+	bind(i *instance) callable
 }
 
 type class struct {
 	name    string
 	super   *class
-	methods map[string]*function
+	methods map[string]method
+	fields  map[string]Field
 }
 
-func (c *class) arity() int {
+func (c *class) arity() (int, int) {
 	initializer := c.findMethod("init")
 	if initializer != nil {
 		return initializer.arity()
 	}
 
-	return 0
+	return 0, 0
 }
 
 func (c *class) call(i *Interpreter, args []interface{}) interface{} {
-	inst := &instance{c, make(map[string]interface{})}
+	inst := &instance{c, make(map[string]interface{}), nil}
 	initializer := c.findMethod("init")
 	if initializer != nil {
 		initializer.bind(inst).call(i, args)
@@ -100,9 +112,9 @@ func (c *class) call(i *Interpreter, args []interface{}) interface{} {
 	return inst
 }
 
-func (c *class) findMethod(name string) *function {
-	if fn, ok := c.methods[name]; ok {
-		return fn
+func (c *class) findMethod(name string) method {
+	if m, ok := c.methods[name]; ok {
+		return m
 	}
 
 	if c.super != nil {
@@ -112,6 +124,32 @@ func (c *class) findMethod(name string) *function {
 	return nil
 }
 
+func (c *class) findField(name string) (Field, bool) {
+	if f, ok := c.fields[name]; ok {
+		return f, true
+	}
+
+	if c.super != nil {
+		return c.super.findField(name)
+	}
+
+	return Field{}, false
+}
+
 func (c class) String() string {
 	return fmt.Sprintf("<class %s>", c.name)
 }
+
+func checkArity(f callable, argc int) string {
+	min, max := f.arity()
+	switch {
+	case min == max && argc != min:
+		return fmt.Sprintf("expected %d arguments but got %d", min, argc)
+	case max == Variadic && argc < min:
+		return fmt.Sprintf("expected at least %d arguments but got %d", min, argc)
+	case max != Variadic && (argc < min || argc > max):
+		return fmt.Sprintf("expected %d to %d arguments but got %d", min, max, argc)
+	}
+
+	return ""
+}
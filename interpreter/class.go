@@ -0,0 +1,105 @@
+package interpreter
+
+// Class describes a class implemented in Go. Lox code can call it,
+// subclass it and reach its methods through super like any other class.
+// A method named "init" runs when an instance is created.
+type Class struct {
+	Methods map[string]Method
+	Fields  map[string]Field
+}
+
+// Method is a method of a Class taking between Min and Max arguments, or
+// any number from Min when Max is Variadic.
+type Method struct {
+	Min int
+	Max int
+	Fn  func(this *Object, args []interface{}) (interface{}, error)
+}
+
+// Field is a property of a Class read and written through Go. A nil Set
+// makes the field read-only.
+type Field struct {
+	Get func(this *Object) (interface{}, error)
+	Set func(this *Object, value interface{}) error
+}
+
+// Object is the instance a native method or field is used on, which may
+// belong to a Lox subclass of the native class.
+type Object struct {
+	inst *instance
+}
+
+// Class returns the name of the object's class.
+func (o *Object) Class() string {
+	return o.inst.c.name
+}
+
+// Get returns a field stored on the object by Lox code or Set.
+func (o *Object) Get(name string) (interface{}, bool) {
+	value, ok := o.inst.fields[name]
+	return toGo(value, make(map[interface{}]interface{})), ok
+}
+
+// Set stores a field on the object, visible to Lox code.
+func (o *Object) Set(name string, value interface{}) error {
+	v, err := fromGo(value)
+	if err != nil {
+		return err
+	}
+
+	o.inst.fields[name] = v
+	return nil
+}
+
+// Data returns the Go value attached to the object with SetData.
+func (o *Object) Data() interface{} {
+	return o.inst.data
+}
+
+// SetData attaches a Go value to the object that Lox code cannot see.
+func (o *Object) SetData(data interface{}) {
+	o.inst.data = data
+}
+
+type nativeMethod struct {
+	name string
+	min  int
+	max  int
+	fn   func(i *Interpreter, this *instance, args []interface{}) interface{}
+}
+
+func (m *nativeMethod) arity() (int, int) { return m.min, m.max }
+
+func (m *nativeMethod) bind(inst *instance) callable {
+	return &native{m.name, m.min, m.max, func(i *Interpreter, args []interface{}) interface{} {
+		result := m.fn(i, inst, args)
+		if m.name == "init" {
+			return inst
+		}
+		return result
+	}}
+}
+
+// RegisterClass defines a global class implemented in Go.
+func (i *Interpreter) RegisterClass(name string, spec Class) {
+	c := &class{name, nil, make(map[string]method), make(map[string]Field)}
+	for methodName, m := range spec.Methods {
+		methodName, m := methodName, m
+		c.methods[methodName] = &nativeMethod{methodName, m.Min, m.Max, func(i *Interpreter, this *instance, args []interface{}) interface{} {
+			call := i.call
+			values := make([]interface{}, len(args))
+			for j, arg := range args {
+				values[j] = toGo(arg, make(map[interface{}]interface{}))
+			}
+
+			result, err := m.Fn(&Object{this}, values)
+			return nativeResult(methodName, call, result, err)
+		}}
+	}
+
+	for fieldName, f := range spec.Fields {
+		c.fields[fieldName] = f
+	}
+
+	i.builtins[name] = i.allow(name, c)
+}
@@ -0,0 +1,25 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"github.com/Shri333/golox/scanner"
+	"github.com/Shri333/golox/value"
+)
+
+// collectionMethod returns m, the method name of a list or map, as a native
+// raising its errors at the call.
+func collectionMethod(name *scanner.Token, m *value.Method) *native {
+	if m == nil {
+		message := fmt.Sprintf("undefined property %s", name.Lexeme)
+		panic(faultAt(name, message))
+	}
+
+	return &native{m.Name, m.Arity, m.Arity, func(i *Interpreter, args []interface{}) interface{} {
+		result, err := m.Fn(args)
+		if err != nil {
+			panic(faultAt(i.call, err.Error()))
+		}
+		return result
+	}}
+}
@@ -0,0 +1,197 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/Shri333/golox/fault"
+	"github.com/Shri333/golox/parser"
+	"github.com/Shri333/golox/value"
+)
+
+// SetOutput directs print statements to stdout and uncaught errors to
+// reporter. They default to os.Stdout and a reporter printing to os.Stderr.
+func (i *Interpreter) SetOutput(stdout io.Writer, reporter fault.Reporter) {
+	i.stdout = stdout
+	i.reporter = reporter
+}
+
+// Evaluate runs stmts like Interpret and returns the value of the last one
+// if it is an expression statement, converted to a Go value.
+func (i *Interpreter) Evaluate(stmts []parser.Stmt) (interface{}, error) {
+	var value interface{}
+	err := i.protect(func() {
+		for n, stmt := range stmts {
+			if e, ok := stmt.(*parser.ExprStmt); ok && n == len(stmts)-1 {
+				value = e.Expression.Accept(i)
+			} else {
+				stmt.Accept(i)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toGo(value, make(map[interface{}]interface{})), nil
+}
+
+// Global returns the Go value of a global variable of the top-level script.
+func (i *Interpreter) Global(name string) (interface{}, bool) {
+	value, ok := i.module.globals[name]
+	if !ok {
+		value, ok = i.builtins[name]
+	}
+
+	return toGo(value, make(map[interface{}]interface{})), ok
+}
+
+// SetGlobal defines or overwrites a global variable of the top-level script.
+func (i *Interpreter) SetGlobal(name string, value interface{}) error {
+	v, err := fromGo(value)
+	if err != nil {
+		return err
+	}
+
+	i.module.globals[name] = v
+	return nil
+}
+
+// Call calls the global function, class or native called name with args
+// converted to Lox values, and returns its result converted back to Go.
+func (i *Interpreter) Call(name string, args ...interface{}) (interface{}, error) {
+	value, ok := i.module.globals[name]
+	if !ok {
+		value, ok = i.builtins[name]
+	}
+	if !ok {
+		return nil, fmt.Errorf("undefined variable '%s'", name)
+	}
+
+	f, ok := value.(callable)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a function or class", name)
+	}
+
+	if message := checkArity(f, len(args)); message != "" {
+		return nil, errors.New(message)
+	}
+
+	values := make([]interface{}, len(args))
+	for n, arg := range args {
+		v, err := fromGo(arg)
+		if err != nil {
+			return nil, err
+		}
+		values[n] = v
+	}
+
+	// A run nested in a native's call is traced as called from there.
+	site := i.call
+	if i.budget.nesting == 0 {
+		site = nil
+	}
+	var result interface{}
+	err := i.protect(func() {
+		i.call = site
+		result = f.call(i, values)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toGo(result, make(map[interface{}]interface{})), nil
+}
+
+// toGo turns lists into []interface{} and maps into
+// map[interface{}]interface{}. Numbers, strings, booleans and nil are
+// already Go values; functions, classes and instances are passed through
+// as opaque values that can be handed back to the interpreter.
+func toGo(v interface{}, seen map[interface{}]interface{}) interface{} {
+	switch v := v.(type) {
+	case *value.List:
+		if converted, ok := seen[v]; ok {
+			return converted
+		}
+		elements := make([]interface{}, len(v.Elements))
+		seen[v] = elements
+		for n, element := range v.Elements {
+			elements[n] = toGo(element, seen)
+		}
+		return elements
+	case *value.Map:
+		if converted, ok := seen[v]; ok {
+			return converted
+		}
+		entries := make(map[interface{}]interface{}, len(v.Keys))
+		seen[v] = entries
+		for n, key := range v.Keys {
+			entries[key] = toGo(v.Values[n], seen)
+		}
+		return entries
+	}
+
+	return v
+}
+
+// fromGo turns Go numbers, strings and booleans of any type into Lox
+// values, slices and arrays into lists and maps into Lox maps, and lets
+// values that came out of the interpreter through unchanged.
+func fromGo(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil, bool, string, float64:
+		return v, nil
+	case *value.List, *value.Map, *function, *native, *class, *instance, *module, *loxError:
+		return v, nil
+	case *Object:
+		return v.inst, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Slice, reflect.Array:
+		elements := make([]interface{}, rv.Len())
+		for n := range elements {
+			converted, err := fromGo(rv.Index(n).Interface())
+			if err != nil {
+				return nil, err
+			}
+			elements[n] = converted
+		}
+		return value.NewList(elements), nil
+	case reflect.Map:
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(a, b int) bool { return fmt.Sprint(keys[a]) < fmt.Sprint(keys[b]) })
+
+		d := value.NewMap()
+		for _, key := range keys {
+			k, err := fromGo(key.Interface())
+			if err != nil {
+				return nil, err
+			}
+			converted, err := fromGo(rv.MapIndex(key).Interface())
+			if err != nil {
+				return nil, err
+			}
+			if err := d.Set(k, converted); err != nil {
+				return nil, err
+			}
+		}
+		return d, nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %T to a Lox v", v)
+}
@@ -3,57 +3,55 @@ package interpreter
 import (
 	"fmt"
 
-	"github.com/Shri333/golox/fault"
+	"github.com/Shri333/golox/parser"
 	"github.com/Shri333/golox/scanner"
 )
 
+// environment holds the locals of one scope in the order the resolver
+// declared them, so define appends and lookups index by the resolved slot.
 type environment struct {
 	enclosing *environment
-	values    map[string]interface{}
+	values    []interface{}
 }
 
-func (e *environment) get(name *scanner.Token) interface{} {
-	if value, ok := e.values[name.Lexeme]; ok {
-		return value
-	}
-
-	if e.enclosing != nil {
-		return e.enclosing.get(name)
-	}
+func (e *environment) getAt(local *parser.Local) interface{} {
+	return e.ancestor(local.Depth).values[local.Slot]
+}
 
-	message := fmt.Sprintf("undefined variable '%s'", name.Lexeme)
-	panic(fault.NewFault(name.Line, message))
+func (e *environment) assignAt(local *parser.Local, value interface{}) {
+	e.ancestor(local.Depth).values[local.Slot] = value
 }
 
-func (e *environment) getAt(name string, dist int) interface{} {
+func (e *environment) ancestor(dist int) *environment {
 	ancestor := e
 	for i := 0; i < dist; i++ {
 		ancestor = ancestor.enclosing
 	}
 
-	return ancestor.values[name]
+	return ancestor
 }
 
-func (e *environment) assign(name *scanner.Token, value interface{}) {
-	if _, ok := e.values[name.Lexeme]; ok {
-		e.values[name.Lexeme] = value
-	} else if e.enclosing != nil {
-		e.enclosing.assign(name, value)
-	} else {
-		message := fmt.Sprintf("undefined variable '%s'", name.Lexeme)
-		panic(fault.NewFault(name.Line, message))
-	}
+func (e *environment) define(value interface{}) int {
+	e.values = append(e.values, value)
+	return len(e.values) - 1
 }
 
-func (e *environment) assignAt(name string, value interface{}, dist int) {
-	ancestor := e
-	for i := 0; i < dist; i++ {
-		ancestor = ancestor.enclosing
+type globals map[string]interface{}
+
+func (g globals) get(name *scanner.Token) interface{} {
+	if value, ok := g[name.Lexeme]; ok {
+		return value
 	}
 
-	ancestor.values[name] = value
+	message := fmt.Sprintf("undefined variable '%s'", name.Lexeme)
+	panic(faultAt(name, message))
 }
 
-func (e *environment) define(name string, value interface{}) {
-	e.values[name] = value
+func (g globals) assign(name *scanner.Token, value interface{}) {
+	if _, ok := g[name.Lexeme]; !ok {
+		message := fmt.Sprintf("undefined variable '%s'", name.Lexeme)
+		panic(faultAt(name, message))
+	}
+
+	g[name.Lexeme] = value
 }
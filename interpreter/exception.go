@@ -0,0 +1,124 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"github.com/Shri333/golox/fault"
+	"github.com/Shri333/golox/scanner"
+	"github.com/Shri333/golox/value"
+)
+
+// thrown is the panic value carrying a Lox exception up to the nearest try
+// statement. Runtime faults are wrapped into one when a try catches them.
+type thrown struct {
+	value  interface{}
+	line   int
+	column int
+	end    int
+	file   string
+	trace  []fault.Frame
+}
+
+func (t *thrown) fault() *fault.Fault {
+	if e, ok := t.value.(*loxError); ok {
+		return e.fault
+	}
+
+	f := fault.NewAt(t.line, t.column, t.end, "uncaught exception: "+value.Stringify(t.value))
+	f.SetTrace(t.trace)
+	f.SetFile(t.file)
+	return f
+}
+
+// frame is a call in progress, named after the function and the line it
+// was called from, running code from file.
+type frame struct {
+	name string
+	line int
+	file string
+}
+
+// faultAt returns a fault underlining t, the token that caused it.
+func faultAt(t *scanner.Token, message string) *fault.Fault {
+	if t == nil {
+		return fault.New(0, message)
+	}
+
+	span := t.Span()
+	if span.EndLine != span.Line {
+		span.EndColumn = span.Column
+	}
+
+	return fault.NewAt(span.Line, span.Column, span.EndColumn, message)
+}
+
+// intercept turns a recovered panic into the exception it carries, taking a
+// stack trace the first time the exception is caught. Frames are only
+// popped when calls return normally, so i.frames still shows where the
+// exception was raised.
+func (i *Interpreter) intercept(r interface{}) *thrown {
+	t, ok := r.(*thrown)
+	if !ok {
+		f, ok := r.(*fault.Fault)
+		if !ok {
+			panic(r)
+		}
+		column, end := f.Columns()
+		t = &thrown{&loxError{f}, f.Line(), column, end, "", nil}
+	}
+
+	if t.trace != nil {
+		return t
+	}
+
+	e, ok := t.value.(*loxError)
+	if ok && e.fault.Trace() != nil {
+		t.trace, t.file = e.fault.Trace(), e.fault.File()
+		return t
+	}
+
+	t.trace, t.file = i.traceback(t.line), i.module.path
+	if len(i.frames) > 0 {
+		t.file = i.frames[len(i.frames)-1].file
+	}
+	if ok {
+		e.fault.SetTrace(t.trace)
+		e.fault.SetFile(t.file)
+	}
+
+	return t
+}
+
+func (i *Interpreter) traceback(line int) []fault.Frame {
+	trace := make([]fault.Frame, 0, len(i.frames)+1)
+	for j := len(i.frames) - 1; j >= 0; j-- {
+		trace = append(trace, fault.Frame{Function: i.frames[j].name, Line: line})
+		line = i.frames[j].line
+	}
+
+	if line == 0 {
+		return trace
+	}
+
+	return append(trace, fault.Frame{Function: "script", Line: line})
+}
+
+type loxError struct {
+	fault *fault.Fault
+}
+
+func (e *loxError) get(name *scanner.Token) interface{} {
+	switch name.Lexeme {
+	case "message":
+		return e.fault.Message()
+	case "line":
+		return float64(e.fault.Line())
+	}
+
+	message := fmt.Sprintf("undefined property %s", name.Lexeme)
+	panic(faultAt(name, message))
+}
+
+func (e loxError) String() string {
+	return e.fault.Error()
+}
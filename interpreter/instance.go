@@ -3,13 +3,13 @@ package interpreter
 import (
 	"fmt"
 
-	"github.com/Shri333/golox/fault"
 	"github.com/Shri333/golox/scanner"
 )
 
 type instance struct {
 	c      *class
 	fields map[string]interface{}
+	data   interface{}
 }
 
 func (i *instance) get(name *scanner.Token) interface{} {
@@ -17,19 +17,39 @@ func (i *instance) get(name *scanner.Token) interface{} {
 		return value
 	}
 
+	if field, ok := i.c.findField(name.Lexeme); ok && field.Get != nil {
+		value, err := field.Get(&Object{i})
+		return nativeResult(name.Lexeme, name, value, err)
+	}
+
 	method := i.c.findMethod(name.Lexeme)
 	if method != nil {
 		return method.bind(i)
 	}
 
 	message := fmt.Sprintf("undefined property %s", name.Lexeme)
-	panic(fault.NewFault(name.Line, message))
+	panic(faultAt(name, message))
 }
 
 func (i *instance) set(name *scanner.Token, value interface{}) {
+	if field, ok := i.c.findField(name.Lexeme); ok {
+		if field.Set == nil {
+			message := fmt.Sprintf("cannot assign to read-only field %s", name.Lexeme)
+			panic(faultAt(name, message))
+		}
+		if err := field.Set(&Object{i}, toGo(value, make(map[interface{}]interface{}))); err != nil {
+			nativeError(name, err)
+		}
+		return
+	}
+
 	i.fields[name.Lexeme] = value
 }
 
 func (i instance) String() string {
 	return fmt.Sprintf("%s instance", i.c.name)
 }
+
+func (i *instance) ClassName() string {
+	return i.c.name
+}
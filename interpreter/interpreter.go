@@ -1,61 +1,134 @@
 package interpreter
 
 import (
+	"context"
 	"fmt"
-	"strconv"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/Shri333/golox/fault"
 	"github.com/Shri333/golox/parser"
 	"github.com/Shri333/golox/scanner"
+	"github.com/Shri333/golox/value"
+)
+
+type signal int
+
+const (
+	S_NORMAL signal = iota
+	S_RETURN
+	S_BREAK
+	S_CONTINUE
 )
 
 type Interpreter struct {
-	global  *environment
-	current *environment
-	locals  map[parser.Expr]int
+	stdout   io.Writer
+	reporter fault.Reporter
+	builtins globals
+	module   *module
+	current  *environment
+	returned interface{}
+	modules  map[string]*module
+	search   []string
+	call     *scanner.Token
+	frames   []frame
+	depth    int
+	budget   budget
+	policy   Policy
 }
 
+// MaxDepth is the default limit on nested calls; see parser.MAX_DEPTH.
+const MaxDepth = parser.MAX_DEPTH
+
 func NewInterpreter() *Interpreter {
-	global := &environment{nil, make(map[string]interface{})}
-	global.define("clock", &clock{})
-	return &Interpreter{global, global, make(map[parser.Expr]int)}
+	builtins := globals{"clock": &native{"clock", 0, 0, clock}}
+	return &Interpreter{os.Stdout, fault.NewReporter(os.Stderr), builtins, newModule(""), nil, nil, make(map[string]*module), nil, nil, nil, MaxDepth, budget{context.Background(), 0, 0, nil, 0, 0}, AllowAll()}
 }
 
-func (i *Interpreter) Interpret(stmts []parser.Stmt) (err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			err = r.(error)
-		}
-	}()
+// SetScript records the file the top-level statements come from, so that
+// its imports are found relative to it and importing it back is a cycle.
+func (i *Interpreter) SetScript(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
 
-	for _, stmt := range stmts {
-		stmt.Accept(i)
+	delete(i.modules, i.module.path)
+	i.module.name = newModule(abs).name
+	i.module.path = abs
+	i.modules[abs] = i.module
+	return nil
+}
+
+// SetSearchPath sets the directories searched for imports that are not
+// found next to the importing file.
+func (i *Interpreter) SetSearchPath(dirs []string) {
+	i.search = dirs
+}
+
+// SetMaxDepth limits how deeply calls and imports may nest before a stack
+// overflow error is raised.
+func (i *Interpreter) SetMaxDepth(depth int) {
+	i.depth = depth
+}
+
+// enter pushes a frame for code from file, called or imported at site.
+func (i *Interpreter) enter(name string, file string, site *scanner.Token) {
+	if len(i.frames)+1 >= i.depth {
+		panic(faultAt(site, "stack overflow"))
 	}
 
-	return
+	line := 0
+	if site != nil {
+		line = site.Line
+	}
+	i.frames = append(i.frames, frame{name, line, file})
+}
+
+func (i *Interpreter) Interpret(stmts []parser.Stmt) error {
+	return i.protect(func() {
+		for _, stmt := range stmts {
+			stmt.Accept(i)
+		}
+	})
 }
 
-func (i *Interpreter) Resolve(expr parser.Expr, depth int) {
-	i.locals[expr] = depth
+// protect runs fn and turns a fault or exception escaping from it into the
+// returned error, which is also reported. Errors of a nested run are left
+// for the outermost run to report, since Lox code may still catch them.
+func (i *Interpreter) protect(fn func()) (err error) {
+	depth := len(i.frames)
+	nested := i.budget.nesting > 0
+	defer func() {
+		if r := recover(); r != nil {
+			if a, ok := r.(*Aborted); ok {
+				err = a
+			} else {
+				err = i.intercept(r).fault()
+			}
+			i.frames = i.frames[:depth]
+			if !nested {
+				i.reporter.Report(err)
+			}
+		}
+	}()
+	cancel := i.start()
+	defer cancel()
+
+	fn()
+	return nil
 }
 
 func (i *Interpreter) VisitExprStmt(e *parser.ExprStmt) interface{} {
 	e.Expression.Accept(i)
-	return nil
+	return S_NORMAL
 }
 
 func (i *Interpreter) VisitPrintStmt(p *parser.PrintStmt) interface{} {
-	value := p.Expression.Accept(i)
-	switch v := value.(type) {
-	case float64:
-		fmt.Fprintln(fault.W, strconv.FormatFloat(v, 'f', -1, 64))
-	case bool:
-		fmt.Fprintln(fault.W, strconv.FormatBool(v))
-	default:
-		fmt.Fprintln(fault.W, v)
-	}
-
-	return nil
+	fmt.Fprintln(i.stdout, value.Stringify(p.Expression.Accept(i)))
+	return S_NORMAL
 }
 
 func (i *Interpreter) VisitVarStmt(v *parser.VarStmt) interface{} {
@@ -64,45 +137,47 @@ func (i *Interpreter) VisitVarStmt(v *parser.VarStmt) interface{} {
 		value = v.Initializer.Accept(i)
 	}
 
-	i.current.define(v.Name.Lexeme, value)
-	return nil
+	i.define(v.Name, value)
+	return S_NORMAL
 }
 
 func (i *Interpreter) VisitBlockStmt(b *parser.BlockStmt) interface{} {
-	prev := i.current
-	defer func() { i.current = prev }()
-
-	i.current = &environment{prev, make(map[string]interface{})}
-	for _, stmt := range b.Statements {
-		stmt.Accept(i)
-	}
-
-	return nil
+	return i.executeBlock(b.Statements, &environment{i.current, nil})
 }
 
 func (i *Interpreter) VisitIfStmt(i_ *parser.IfStmt) interface{} {
 	value := i_.Condition.Accept(i)
 	if isTruthy(value) {
-		i_.ThenBranch.Accept(i)
+		return i_.ThenBranch.Accept(i)
 	} else if i_.ElseBranch != nil {
-		i_.ElseBranch.Accept(i)
+		return i_.ElseBranch.Accept(i)
 	}
 
-	return nil
+	return S_NORMAL
 }
 
 func (i *Interpreter) VisitWhileStmt(w *parser.WhileStmt) interface{} {
 	for isTruthy(w.Condition.Accept(i)) {
-		w.Body.Accept(i)
+		i.step()
+		s := w.Body.Accept(i).(signal)
+		if s == S_BREAK {
+			break
+		} else if s == S_RETURN {
+			return s
+		}
+
+		if w.Increment != nil {
+			w.Increment.Accept(i)
+		}
 	}
 
-	return nil
+	return S_NORMAL
 }
 
 func (i *Interpreter) VisitFunStmt(f *parser.FunStmt) interface{} {
-	fn := &function{f, i.current, false}
-	i.current.define(f.Name.Lexeme, fn)
-	return nil
+	fn := &function{f.Name.Lexeme, f.Params, f.Body, i.current, i.module, false}
+	i.define(f.Name, fn)
+	return S_NORMAL
 }
 
 func (i *Interpreter) VisitReturnStmt(v *parser.ReturnStmt) interface{} {
@@ -111,7 +186,55 @@ func (i *Interpreter) VisitReturnStmt(v *parser.ReturnStmt) interface{} {
 		value = v.Value.Accept(i)
 	}
 
-	panic(value)
+	i.returned = value
+	return S_RETURN
+}
+
+func (i *Interpreter) VisitThrowStmt(t *parser.ThrowStmt) interface{} {
+	value := t.Value.Accept(i)
+	span := t.Keyword.Span()
+	panic(&thrown{value, span.Line, span.Column, span.EndColumn, "", nil})
+}
+
+func (i *Interpreter) VisitTryStmt(t *parser.TryStmt) interface{} {
+	s, caught := i.attempt(t.Body.Statements, &environment{i.current, nil})
+	if caught != nil && t.Catch != nil {
+		env := &environment{i.current, []interface{}{caught.value}}
+		s, caught = i.attempt(t.Catch.Statements, env)
+	}
+
+	if t.Finally != nil {
+		returned := i.returned
+		if s := i.executeBlock(t.Finally.Statements, &environment{i.current, nil}); s != S_NORMAL {
+			return s
+		}
+		i.returned = returned
+	}
+
+	if caught != nil {
+		panic(caught)
+	}
+
+	return s
+}
+
+// attempt runs a block like executeBlock, but stops a thrown value or a
+// runtime fault and hands it back instead of letting it unwind further.
+func (i *Interpreter) attempt(stmts []parser.Stmt, env *environment) (s signal, caught *thrown) {
+	depth := len(i.frames)
+	defer func() {
+		if r := recover(); r != nil {
+			caught = i.intercept(r)
+			i.frames = i.frames[:depth]
+		}
+	}()
+
+	return i.executeBlock(stmts, env), nil
+}
+
+func (i *Interpreter) VisitImportStmt(s *parser.ImportStmt) interface{} {
+	i.define(s.Name, i.importModule(s.Keyword, s.Path.Literal.(string)))
+	return S_NORMAL
 }
 
 func (i *Interpreter) VisitClassStmt(c *parser.ClassStmt) interface{} {
@@ -121,31 +244,34 @@ func (i *Interpreter) VisitClassStmt(c *parser.ClassStmt) interface{} {
 			super = value
 		} else {
 			message := fmt.Sprintf("%s is a not a class", c.Super.Name.Lexeme)
-			panic(fault.NewFault(c.Super.Name.Line, message))
+			panic(faultAt(c.Super.Name, message))
 		}
 	}
 
-	i.current.define(c.Name.Lexeme, nil)
 	if c.Super != nil {
-		i.current = &environment{i.current, make(map[string]interface{})}
-		i.current.define("super", super)
+		i.current = &environment{i.current, []interface{}{super}}
 	}
 
-	methods := make(map[string]*function)
+	methods := make(map[string]method)
 	for _, method := range c.Methods {
-		if method.Name.Lexeme == "init" {
-			methods[method.Name.Lexeme] = &function{method, i.current, true}
-		} else {
-			methods[method.Name.Lexeme] = &function{method, i.current, false}
-		}
+		init := method.Name.Lexeme == "init"
+		methods[method.Name.Lexeme] = &function{method.Name.Lexeme, method.Params, method.Body, i.current, i.module, init}
 	}
 
 	if c.Super != nil {
 		i.current = i.current.enclosing
 	}
 
-	i.current.assign(c.Name, &class{c.Name.Lexeme, super, methods})
-	return nil
+	i.define(c.Name, &class{c.Name.Lexeme, super, methods, nil})
+	return S_NORMAL
+}
+
+func (i *Interpreter) VisitBreakStmt(b *parser.BreakStmt) interface{} {
+	return S_BREAK
+}
+
+func (i *Interpreter) VisitContinueStmt(c *parser.ContinueStmt) interface{} {
+	return S_CONTINUE
 }
 
 func (i *Interpreter) VisitBinaryExpr(b *parser.BinaryExpr) interface{} {
@@ -184,7 +310,7 @@ func (i *Interpreter) VisitBinaryExpr(b *parser.BinaryExpr) interface{} {
 			}
 		}
 
-		panic(fault.NewFault(b.Operator.Line, "operands must be two numbers or two strings"))
+		panic(faultAt(b.Operator, "operands must be two numbers or two strings"))
 	case scanner.SLASH:
 		leftValue, rightValue := i.checkNumberOperands(b.Operator, left, right)
 		return leftValue / rightValue
@@ -211,7 +337,7 @@ func (i *Interpreter) VisitUnaryExpr(u *parser.UnaryExpr) interface{} {
 			return -value
 		}
 
-		panic(fault.NewFault(u.Operator.Line, "operand must be a number"))
+		panic(faultAt(u.Operator, "operand must be a number"))
 	}
 
 	if u.Operator.TokenType == scanner.BANG {
@@ -229,19 +355,19 @@ func (i *Interpreter) VisitUnaryExpr(u *parser.UnaryExpr) interface{} {
 }
 
 func (i *Interpreter) VisitVariableExpr(v *parser.VariableExpr) interface{} {
-	if dist, ok := i.locals[v]; ok {
-		return i.current.getAt(v.Name.Lexeme, dist)
+	if v.Local != nil {
+		return i.current.getAt(v.Local)
 	}
 
-	return i.global.get(v.Name)
+	return i.lookup(v.Name)
 }
 
 func (i *Interpreter) VisitAssignExpr(a *parser.AssignExpr) interface{} {
 	value := a.Value.Accept(i)
-	if dist, ok := i.locals[a]; ok {
-		i.current.assignAt(a.Name.Lexeme, value, dist)
+	if a.Local != nil {
+		i.current.assignAt(a.Local, value)
 	} else {
-		i.global.assign(a.Name, value)
+		i.assignGlobal(a.Name, value)
 	}
 
 	return value
@@ -249,7 +375,11 @@ func (i *Interpreter) VisitAssignExpr(a *parser.AssignExpr) interface{} {
 
 func (i *Interpreter) VisitLogicalExpr(l *parser.LogicalExpr) interface{} {
 	left := l.Left.Accept(i)
-	if (l.Operator.TokenType == scanner.OR && isTruthy(left)) || !isTruthy(left) {
+	if l.Operator.TokenType == scanner.OR {
+		if isTruthy(left) {
+			return left
+		}
+	} else if !isTruthy(left) {
 		return left
 	}
 
@@ -264,15 +394,16 @@ func (i *Interpreter) VisitCallExpr(c *parser.CallExpr) interface{} {
 	}
 
 	if f, ok := callee.(callable); ok {
-		if len(args) != f.arity() {
-			message := fmt.Sprintf("expected %d arguments but got %d", f.arity(), len(args))
-			panic(fault.NewFault(c.Paren.Line, message))
+		if message := checkArity(f, len(args)); message != "" {
+			panic(faultAt(&c.Paren, message))
 		}
 
+		i.step()
+		i.call = &c.Paren
 		return f.call(i, args)
 	}
 
-	panic(fault.NewFault(c.Paren.Line, "can only call functions and classes"))
+	panic(faultAt(&c.Paren, "can only call functions and classes"))
 }
 
 func (i *Interpreter) VisitGetExpr(g *parser.GetExpr) interface{} {
@@ -281,7 +412,19 @@ func (i *Interpreter) VisitGetExpr(g *parser.GetExpr) interface{} {
 		return o.get(g.Name)
 	}
 
-	panic(fault.NewFault(g.Name.Line, "only instances have properties"))
+	if c, ok := object.(value.Collection); ok {
+		return collectionMethod(g.Name, c.Method(g.Name.Lexeme))
+	}
+
+	if e, ok := object.(*loxError); ok {
+		return e.get(g.Name)
+	}
+
+	if m, ok := object.(*module); ok {
+		return m.get(g.Name)
+	}
+
+	panic(faultAt(g.Name, "only instances have properties"))
 }
 
 func (i *Interpreter) VisitSetExpr(s *parser.SetExpr) interface{} {
@@ -292,30 +435,125 @@ func (i *Interpreter) VisitSetExpr(s *parser.SetExpr) interface{} {
 		return value
 	}
 
-	panic(fault.NewFault(s.Name.Line, "only instances have fields"))
+	panic(faultAt(s.Name, "only instances have fields"))
 }
 
 func (i *Interpreter) VisitThisExpr(t *parser.ThisExpr) interface{} {
-	if dist, ok := i.locals[t]; ok {
-		return i.current.getAt(t.Keyword.Lexeme, dist)
+	if t.Local != nil {
+		return i.current.getAt(t.Local)
 	}
 
-	return i.global.get(t.Keyword)
+	return i.lookup(t.Keyword)
 }
 
 func (i *Interpreter) VisitSuperExpr(s *parser.SuperExpr) interface{} {
-	dist := i.locals[s]
-	super := i.current.getAt("super", dist).(*class)
-	object := i.current.getAt("this", dist-1).(*instance)
+	super := i.current.getAt(s.Local).(*class)
+	object := i.current.ancestor(s.Local.Depth - 1).values[0].(*instance)
 	method := super.findMethod(s.Method.Lexeme)
 	if method == nil {
 		message := fmt.Sprintf("undefined property '%s'", s.Method.Lexeme)
-		panic(fault.NewFault(s.Method.Line, message))
+		panic(faultAt(s.Method, message))
 	}
 
 	return method.bind(object)
 }
 
+func (i *Interpreter) executeBlock(stmts []parser.Stmt, env *environment) signal {
+	prev := i.current
+	defer func() { i.current = prev }()
+
+	i.current = env
+	for _, stmt := range stmts {
+		if s := stmt.Accept(i).(signal); s != S_NORMAL {
+			return s
+		}
+	}
+
+	return S_NORMAL
+}
+
+func (i *Interpreter) lookup(name *scanner.Token) interface{} {
+	if value, ok := i.module.globals[name.Lexeme]; ok {
+		return value
+	}
+
+	return i.builtins.get(name)
+}
+
+func (i *Interpreter) assignGlobal(name *scanner.Token, value interface{}) {
+	if _, ok := i.builtins[name.Lexeme]; ok {
+		i.module.globals[name.Lexeme] = value
+	} else {
+		i.module.globals.assign(name, value)
+	}
+}
+
+func (i *Interpreter) define(name *scanner.Token, value interface{}) {
+	if i.current == nil {
+		i.module.globals[name.Lexeme] = value
+	} else {
+		i.current.define(value)
+	}
+}
+
+func (i *Interpreter) VisitFunctionExpr(f *parser.FunctionExpr) interface{} {
+	name := fmt.Sprintf("anonymous@%d", f.Keyword.Line)
+	return &function{name, f.Params, f.Body, i.current, i.module, false}
+}
+
+func (i *Interpreter) VisitListExpr(l *parser.ListExpr) interface{} {
+	elements := make([]interface{}, 0, len(l.Elements))
+	for _, element := range l.Elements {
+		elements = append(elements, element.Accept(i))
+	}
+
+	return value.NewList(elements)
+}
+
+func (i *Interpreter) VisitIndexGetExpr(e *parser.IndexGetExpr) interface{} {
+	object := e.Object.Accept(i)
+	index := e.Index.Accept(i)
+	if c, ok := object.(value.Collection); ok {
+		element, err := c.Get(index)
+		if err != nil {
+			panic(faultAt(e.Bracket, err.Error()))
+		}
+		return element
+	}
+
+	panic(faultAt(e.Bracket, "only lists and maps can be indexed"))
+}
+
+func (i *Interpreter) VisitIndexSetExpr(e *parser.IndexSetExpr) interface{} {
+	object := e.Object.Accept(i)
+	index := e.Index.Accept(i)
+	element := e.Value.Accept(i)
+	if c, ok := object.(value.Collection); ok {
+		if err := c.Set(index, element); err != nil {
+			panic(faultAt(e.Bracket, err.Error()))
+		}
+		return element
+	}
+
+	panic(faultAt(e.Bracket, "only lists and maps can be indexed"))
+}
+
+func (i *Interpreter) VisitMapExpr(m *parser.MapExpr) interface{} {
+	entries := make([]interface{}, 0, 2*len(m.Keys))
+	for j := range m.Keys {
+		entries = append(entries, m.Keys[j].Accept(i), m.Values[j].Accept(i))
+	}
+
+	d := value.NewMap()
+	for j := 0; j < len(entries); j += 2 {
+		if err := d.Set(entries[j], entries[j+1]); err != nil {
+			panic(faultAt(m.Brace, err.Error()))
+		}
+	}
+
+	return d
+}
+
 func (i *Interpreter) checkNumberOperands(operator *scanner.Token, left interface{}, right interface{}) (float64, float64) {
 	if leftValue, leftOk := left.(float64); leftOk {
 		if rightValue, rightOk := right.(float64); rightOk {
@@ -323,7 +561,7 @@ func (i *Interpreter) checkNumberOperands(operator *scanner.Token, left interfac
 		}
 	}
 
-	panic(fault.NewFault(operator.Line, "operands must be numbers"))
+	panic(faultAt(operator, "operands must be numbers"))
 }
 
 func isTruthy(value interface{}) bool {
@@ -337,3 +575,12 @@ func isTruthy(value interface{}) bool {
 
 	return true
 }
+
+func (i *Interpreter) VisitInterpolationExpr(e *parser.InterpolationExpr) interface{} {
+	var b strings.Builder
+	for _, part := range e.Parts {
+		b.WriteString(value.Stringify(part.Accept(i)))
+	}
+
+	return b.String()
+}
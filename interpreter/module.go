@@ -0,0 +1,83 @@
+package interpreter
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Shri333/golox/loader"
+	"github.com/Shri333/golox/scanner"
+)
+
+// module is the namespace produced by an import. Its globals hold the
+// top-level definitions of the file, which functions declared in it keep
+// using wherever they are called from.
+type module struct {
+	name    string
+	path    string
+	globals globals
+	loaded  bool
+}
+
+func newModule(path string) *module {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return &module{name, path, make(globals), false}
+}
+
+func (m *module) get(name *scanner.Token) interface{} {
+	if value, ok := m.globals[name.Lexeme]; ok {
+		return value
+	}
+
+	message := fmt.Sprintf("undefined property %s", name.Lexeme)
+	panic(faultAt(name, message))
+}
+
+func (m module) String() string {
+	return fmt.Sprintf("<module %s>", m.name)
+}
+
+func (i *Interpreter) importModule(keyword *scanner.Token, path string) *module {
+	if !i.policy.Allows("import") {
+		panic(denied(keyword, "import"))
+	}
+
+	file, err := loader.Find(path, filepath.Dir(i.module.path), i.search)
+	if err != nil {
+		panic(faultAt(keyword, err.Error()))
+	}
+
+	if m, ok := i.modules[file]; ok {
+		if !m.loaded {
+			message := fmt.Sprintf("import cycle: '%s' is already being imported", path)
+			panic(faultAt(keyword, message))
+		}
+		return m
+	}
+
+	stmts, err := loader.Load(file, i.reporter)
+	if err != nil {
+		message := fmt.Sprintf("could not import '%s'", path)
+		panic(faultAt(keyword, message))
+	}
+
+	m := newModule(file)
+	i.modules[file] = m
+	prev, current := i.module, i.current
+	defer func() {
+		i.module, i.current = prev, current
+		if !m.loaded {
+			delete(i.modules, file)
+		}
+	}()
+
+	i.module, i.current = m, nil
+	i.enter(m.name, m.path, keyword)
+	for _, stmt := range stmts {
+		stmt.Accept(i)
+	}
+	i.frames = i.frames[:len(i.frames)-1]
+	m.loaded = true
+
+	return m
+}
@@ -0,0 +1,90 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Shri333/golox/fault"
+	"github.com/Shri333/golox/scanner"
+)
+
+// Policy decides which natives a sandboxed interpreter may call. Natives
+// are named as they are seen from Lox: "clock" for a global function or
+// class and "namespace.name" for one registered with RegisterIn. Loading
+// files with import statements is allowed by the name "import".
+type Policy interface {
+	Allows(native string) bool
+}
+
+// names matches natives by name or by namespace, so "os" covers "os.exit".
+type names map[string]bool
+
+func (n names) match(native string) bool {
+	if n[native] {
+		return true
+	}
+
+	dot := strings.IndexByte(native, '.')
+	return dot >= 0 && n[native[:dot]]
+}
+
+type allowList struct{ names }
+
+func (a allowList) Allows(native string) bool {
+	return a.match(native)
+}
+
+type denyList struct{ names }
+
+func (d denyList) Allows(native string) bool {
+	return !d.match(native)
+}
+
+// AllowAll is the policy of an interpreter made by NewInterpreter.
+func AllowAll() Policy {
+	return denyList{names{}}
+}
+
+// AllowOnly allows the given natives or namespaces and denies the rest.
+func AllowOnly(natives ...string) Policy {
+	return allowList{newNames(natives)}
+}
+
+// Deny denies the given natives or namespaces and allows the rest.
+func Deny(natives ...string) Policy {
+	return denyList{newNames(natives)}
+}
+
+func newNames(natives []string) names {
+	n := make(names)
+	for _, native := range natives {
+		n[native] = true
+	}
+
+	return n
+}
+
+// NewSandbox returns an interpreter restricted by policy. Denied natives are
+// still defined, but raise an error when called.
+func NewSandbox(policy Policy) *Interpreter {
+	i := NewInterpreter()
+	i.policy = policy
+	i.builtins["clock"] = i.allow("clock", i.builtins["clock"])
+	return i
+}
+
+// allow returns value, or a stand-in for it if the policy denies name.
+func (i *Interpreter) allow(name string, value interface{}) interface{} {
+	if i.policy.Allows(name) {
+		return value
+	}
+
+	return &native{name, 0, Variadic, func(i *Interpreter, args []interface{}) interface{} {
+		panic(denied(i.call, name))
+	}}
+}
+
+func denied(t *scanner.Token, name string) *fault.Fault {
+	message := fmt.Sprintf("%s is not allowed by the sandbox policy", name)
+	return faultAt(t, message)
+}
@@ -0,0 +1,241 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/Shri333/golox/fault"
+	"github.com/Shri333/golox/scanner"
+	"github.com/Shri333/golox/value"
+)
+
+// Variadic as the maximum arity lets a function take any number of
+// arguments beyond its minimum.
+const Variadic = -1
+
+// Native is a Go function callable from Lox with between Min and Max
+// arguments. Its arguments and result are converted like those of Call,
+// and a non-nil error is raised as a runtime error on the calling line.
+type Native struct {
+	Min int
+	Max int
+	Fn  func(args []interface{}) (interface{}, error)
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Register defines a global native function visible from every module. fn
+// is either a Native or any Go function, whose parameters decide the arity
+// (a variadic Go function is variadic in Lox too) and the conversions
+// applied to the arguments. Such a function may return a value, an error,
+// or both.
+func (i *Interpreter) Register(name string, fn interface{}) error {
+	n, err := newNative(name, fn)
+	if err != nil {
+		return err
+	}
+
+	i.builtins[name] = i.allow(name, n)
+	return nil
+}
+
+// RegisterIn is like Register but defines the function as a property of the
+// global namespace object called namespace, creating it if needed.
+func (i *Interpreter) RegisterIn(namespace string, name string, fn interface{}) error {
+	n, err := newNative(namespace+"."+name, fn)
+	if err != nil {
+		return err
+	}
+
+	value, ok := i.builtins[namespace]
+	if !ok {
+		value = &module{namespace, "", make(globals), true}
+		i.builtins[namespace] = value
+	}
+
+	m, ok := value.(*module)
+	if !ok {
+		return fmt.Errorf("%s is already defined and is not a namespace", namespace)
+	}
+
+	m.globals[name] = i.allow(namespace+"."+name, n)
+	return nil
+}
+
+func newNative(name string, fn interface{}) (*native, error) {
+	if n, ok := fn.(Native); ok {
+		return &native{name, n.Min, n.Max, func(i *Interpreter, args []interface{}) interface{} {
+			call := i.call
+			values := make([]interface{}, len(args))
+			for j, arg := range args {
+				values[j] = toGo(arg, make(map[interface{}]interface{}))
+			}
+
+			result, err := n.Fn(values)
+			return nativeResult(name, call, result, err)
+		}}, nil
+	}
+
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return nil, fmt.Errorf("cannot register %T as a native function", fn)
+	}
+
+	t := v.Type()
+	if t.NumOut() > 2 || t.NumOut() == 2 && t.Out(1) != errorType {
+		return nil, fmt.Errorf("native function %s must return at most a value and an error", name)
+	}
+
+	min, max := t.NumIn(), t.NumIn()
+	if t.IsVariadic() {
+		min, max = min-1, Variadic
+	}
+
+	return &native{name, min, max, func(i *Interpreter, args []interface{}) interface{} {
+		call := i.call
+		in := make([]reflect.Value, len(args))
+		for j, arg := range args {
+			var param reflect.Type
+			if max == Variadic && j >= min {
+				param = t.In(min).Elem()
+			} else {
+				param = t.In(j)
+			}
+
+			value, ok := toType(arg, param)
+			if !ok {
+				message := fmt.Sprintf("argument %d to %s must be %s", j+1, name, describe(param))
+				panic(faultAt(call, message))
+			}
+			in[j] = value
+		}
+
+		var result interface{}
+		var err error
+		for _, out := range v.Call(in) {
+			if out.Type() != errorType {
+				result = out.Interface()
+			} else if !out.IsNil() {
+				err = out.Interface().(error)
+			}
+		}
+
+		return nativeResult(name, call, result, err)
+	}}, nil
+}
+
+func nativeResult(name string, call *scanner.Token, result interface{}, err error) interface{} {
+	if err != nil {
+		nativeError(call, err)
+	}
+
+	value, err := fromGo(result)
+	if err != nil {
+		message := fmt.Sprintf("native function %s returned an unusable value: %s", name, err)
+		panic(faultAt(call, message))
+	}
+
+	return value
+}
+
+// nativeError raises the error a native returned. Errors from a run the
+// native started are passed on as they are: an Aborted error so that Lox
+// cannot catch it, and a fault so that it keeps its message and trace.
+func nativeError(call *scanner.Token, err error) {
+	var aborted *Aborted
+	if errors.As(err, &aborted) {
+		panic(aborted)
+	}
+	var f *fault.Fault
+	if errors.As(err, &f) {
+		panic(f)
+	}
+
+	panic(faultAt(call, err.Error()))
+}
+
+// toType converts a Lox value into a Go value of type t, reporting false
+// when the value does not fit.
+func toType(v interface{}, t reflect.Type) (reflect.Value, bool) {
+	switch t.Kind() {
+	case reflect.Interface:
+		converted := toGo(v, make(map[interface{}]interface{}))
+		if converted == nil {
+			return reflect.Zero(t), true
+		}
+		rv := reflect.ValueOf(converted)
+		return rv, rv.Type().AssignableTo(t)
+	case reflect.Float32, reflect.Float64:
+		if f, ok := v.(float64); ok {
+			return reflect.ValueOf(f).Convert(t), true
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if f, ok := v.(float64); ok && f == math.Trunc(f) {
+			return reflect.ValueOf(int64(f)).Convert(t), true
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if f, ok := v.(float64); ok && f == math.Trunc(f) && f >= 0 {
+			return reflect.ValueOf(uint64(f)).Convert(t), true
+		}
+	case reflect.String:
+		if s, ok := v.(string); ok {
+			return reflect.ValueOf(s).Convert(t), true
+		}
+	case reflect.Bool:
+		if b, ok := v.(bool); ok {
+			return reflect.ValueOf(b).Convert(t), true
+		}
+	case reflect.Slice:
+		if l, ok := v.(*value.List); ok {
+			s := reflect.MakeSlice(t, len(l.Elements), len(l.Elements))
+			for j, element := range l.Elements {
+				converted, ok := toType(element, t.Elem())
+				if !ok {
+					return reflect.Value{}, false
+				}
+				s.Index(j).Set(converted)
+			}
+			return s, true
+		}
+	case reflect.Map:
+		if d, ok := v.(*value.Map); ok {
+			m := reflect.MakeMapWithSize(t, len(d.Keys))
+			for j, key := range d.Keys {
+				k, ok := toType(key, t.Key())
+				if !ok {
+					return reflect.Value{}, false
+				}
+				e, ok := toType(d.Values[j], t.Elem())
+				if !ok {
+					return reflect.Value{}, false
+				}
+				m.SetMapIndex(k, e)
+			}
+			return m, true
+		}
+	}
+
+	return reflect.Value{}, false
+}
+
+func describe(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "an integer"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice:
+		return "a list"
+	case reflect.Map:
+		return "a map"
+	}
+
+	return t.String()
+}
@@ -0,0 +1,60 @@
+package loader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Shri333/golox/fault"
+	"github.com/Shri333/golox/parser"
+	"github.com/Shri333/golox/resolver"
+	"github.com/Shri333/golox/scanner"
+)
+
+// Find locates the file named by an import, first relative to dir (the
+// directory of the importing file) and then in each search directory. A
+// path without an extension gets ".lox" appended.
+func Find(path string, dir string, search []string) (string, error) {
+	if filepath.Ext(path) == "" {
+		path += ".lox"
+	}
+
+	candidates := []string{path}
+	if !filepath.IsAbs(path) {
+		candidates = []string{filepath.Join(dir, path)}
+		for _, d := range search {
+			candidates = append(candidates, filepath.Join(d, path))
+		}
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return filepath.Abs(candidate)
+		}
+	}
+
+	return "", fmt.Errorf("cannot find module '%s'", path)
+}
+
+// Load reads, scans, parses and resolves the file at path, reporting any
+// error found on the way.
+func Load(path string, reporter fault.Reporter) ([]parser.Stmt, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		reporter.Report(err)
+		return nil, err
+	}
+
+	s := scanner.NewScanner(string(bytes), path, reporter)
+	scanErrs := s.ScanTokens()
+	stmts, errs := parser.NewParser(s.Tokens, path, reporter).Parse()
+	if errs = append(scanErrs, errs...); errs != nil {
+		return nil, errs
+	}
+
+	if err := resolver.NewResolver(path, reporter).Resolve(stmts); err != nil {
+		return nil, err
+	}
+
+	return stmts, nil
+}
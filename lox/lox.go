@@ -0,0 +1,178 @@
+// Package lox runs Lox source code from Go programs.
+//
+// Values cross the boundary as Go values: numbers are float64, strings,
+// booleans and nil map to themselves, lists to []interface{} and maps to
+// map[interface{}]interface{}. Functions, classes and instances come back
+// as opaque values that can be passed back in through SetGlobal or Call.
+package lox
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Shri333/golox/fault"
+	"github.com/Shri333/golox/interpreter"
+	"github.com/Shri333/golox/parser"
+	"github.com/Shri333/golox/resolver"
+	"github.com/Shri333/golox/scanner"
+)
+
+// Native is a Go function with an explicit arity range; see Register.
+type Native = interpreter.Native
+
+// Variadic as a Native's Max accepts any number of arguments.
+const Variadic = interpreter.Variadic
+
+// Class, Method, Field and Object define classes implemented in Go; see
+// RegisterClass.
+type (
+	Class  = interpreter.Class
+	Method = interpreter.Method
+	Field  = interpreter.Field
+	Object = interpreter.Object
+)
+
+// Aborted is the error returned when a script is stopped by its context,
+// timeout or step limit.
+type Aborted = interpreter.Aborted
+
+// ErrStepLimit is the cause of an Aborted error once the step limit is used.
+var ErrStepLimit = interpreter.ErrStepLimit
+
+// Policy decides which natives scripts may call, by name ("clock"),
+// namespaced name ("os.getenv") or namespace ("os"), and whether they may
+// import files ("import").
+type Policy = interpreter.Policy
+
+// AllowOnly allows the given natives or namespaces and denies the rest.
+func AllowOnly(natives ...string) Policy {
+	return interpreter.AllowOnly(natives...)
+}
+
+// Deny denies the given natives or namespaces and allows the rest.
+func Deny(natives ...string) Policy {
+	return interpreter.Deny(natives...)
+}
+
+// Reporter receives every syntax and runtime error; see SetReporter.
+type Reporter = fault.Reporter
+
+// Lox is an interpreter whose globals persist across calls to Eval.
+type Lox struct {
+	interpreter *interpreter.Interpreter
+	stdout      io.Writer
+	reporter    Reporter
+}
+
+func New() *Lox {
+	return NewSandbox(interpreter.AllowAll())
+}
+
+// NewSandbox returns an interpreter that only lets scripts call the natives
+// policy allows; see Policy.
+func NewSandbox(policy Policy) *Lox {
+	l := &Lox{interpreter.NewSandbox(policy), os.Stdout, fault.NewReporter(os.Stderr)}
+	l.interpreter.SetOutput(l.stdout, l.reporter)
+	return l
+}
+
+// SetStdout sets where print statements write.
+func (l *Lox) SetStdout(w io.Writer) {
+	l.stdout = w
+	l.interpreter.SetOutput(l.stdout, l.reporter)
+}
+
+// SetStderr sets where syntax and runtime errors are written, in addition
+// to being returned.
+func (l *Lox) SetStderr(w io.Writer) {
+	l.SetReporter(fault.NewReporter(w))
+}
+
+// SetReporter sets what receives syntax and runtime errors, in addition
+// to them being returned.
+func (l *Lox) SetReporter(r Reporter) {
+	l.reporter = r
+	l.interpreter.SetOutput(l.stdout, l.reporter)
+}
+
+// SetSearchPath sets the directories searched by import statements.
+func (l *Lox) SetSearchPath(dirs []string) {
+	l.interpreter.SetSearchPath(dirs)
+}
+
+// SetContext stops running scripts once ctx is done.
+func (l *Lox) SetContext(ctx context.Context) {
+	l.interpreter.SetContext(ctx)
+}
+
+// SetTimeout stops each Eval or Call after d. Zero means no timeout.
+func (l *Lox) SetTimeout(d time.Duration) {
+	l.interpreter.SetTimeout(d)
+}
+
+// SetStepLimit stops each Eval or Call after n loop iterations and calls.
+// Zero means no limit.
+func (l *Lox) SetStepLimit(n int) {
+	l.interpreter.SetStepLimit(n)
+}
+
+// SetMaxDepth limits how deeply Lox calls may nest before a stack overflow
+// error is raised.
+func (l *Lox) SetMaxDepth(depth int) {
+	l.interpreter.SetMaxDepth(depth)
+}
+
+// Eval runs source and returns the value of its last statement when that is
+// an expression statement, or nil otherwise.
+func (l *Lox) Eval(source string) (interface{}, error) {
+	s := scanner.NewScanner(source, "", l.reporter)
+	scanErrs := s.ScanTokens()
+	stmts, errs := parser.NewParser(s.Tokens, "", l.reporter).Parse()
+	if errs = append(scanErrs, errs...); errs != nil {
+		return nil, errs
+	}
+
+	if err := resolver.NewResolver("", l.reporter).Resolve(stmts); err != nil {
+		return nil, err
+	}
+
+	return l.interpreter.Evaluate(stmts)
+}
+
+// GetGlobal returns the value of a global variable.
+func (l *Lox) GetGlobal(name string) (interface{}, bool) {
+	return l.interpreter.Global(name)
+}
+
+// SetGlobal defines a global variable, or overwrites it if it exists.
+func (l *Lox) SetGlobal(name string, value interface{}) error {
+	return l.interpreter.SetGlobal(name, value)
+}
+
+// Call calls the global function or class called name.
+func (l *Lox) Call(name string, args ...interface{}) (interface{}, error) {
+	return l.interpreter.Call(name, args...)
+}
+
+// Register makes fn callable from Lox as a global function. fn is either a
+// Native or a Go function whose parameter and result types drive the
+// conversion of arguments and return values; a non-nil error it returns
+// is raised as a Lox runtime error on the line of the call.
+func (l *Lox) Register(name string, fn interface{}) error {
+	return l.interpreter.Register(name, fn)
+}
+
+// RegisterIn is like Register but makes fn a property of the global
+// namespace object called namespace, so Lox calls it as namespace.name().
+func (l *Lox) RegisterIn(namespace string, name string, fn interface{}) error {
+	return l.interpreter.RegisterIn(namespace, name, fn)
+}
+
+// RegisterClass defines a global class implemented in Go. Lox code can
+// instantiate it, call its methods, read and write its fields and declare
+// subclasses of it with class Sub < name.
+func (l *Lox) RegisterClass(name string, spec Class) {
+	l.interpreter.RegisterClass(name, spec)
+}
@@ -0,0 +1,458 @@
+package lox_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shri333/golox/fault"
+	"github.com/Shri333/golox/lox"
+)
+
+// newLox returns an interpreter whose print statements go to the returned
+// buffer and whose errors are only returned.
+func newLox(t *testing.T, l *lox.Lox) *bytes.Buffer {
+	t.Helper()
+	var stdout bytes.Buffer
+	l.SetStdout(&stdout)
+	l.SetStderr(io.Discard)
+	return &stdout
+}
+
+func eval(t *testing.T, l *lox.Lox, source string) interface{} {
+	t.Helper()
+	value, err := l.Eval(source)
+	if err != nil {
+		t.Fatalf("Eval(%q): %v", source, err)
+	}
+
+	return value
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		source string
+		want   interface{}
+	}{
+		{"1 + 2;", 3.0},
+		{`"a" + "b";`, "ab"},
+		{"1 < 2;", true},
+		{"nil;", nil},
+		{"print 1;", nil},
+		{`[1, "two", [nil]];`, []interface{}{1.0, "two", []interface{}{nil}}},
+		{`var m = {"k": 1, 2: true}; m;`, map[interface{}]interface{}{"k": 1.0, 2.0: true}},
+		{`var x = 2; "x=${x * 3}";`, "x=6"},
+	}
+
+	for _, test := range tests {
+		l := lox.New()
+		newLox(t, l)
+		if got := eval(t, l, test.source); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Eval(%q) = %#v, want %#v", test.source, got, test.want)
+		}
+	}
+}
+
+func TestEvalKeepsGlobals(t *testing.T) {
+	l := lox.New()
+	stdout := newLox(t, l)
+	eval(t, l, "var count = 1; fun bump() { count = count + 1; }")
+	eval(t, l, "bump(); bump(); print count;")
+	if got := stdout.String(); got != "3\n" {
+		t.Errorf("printed %q, want %q", got, "3\n")
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	l := lox.New()
+	newLox(t, l)
+
+	_, err := l.Eval("var = 1;")
+	var diagnostics fault.Diagnostics
+	if !errors.As(err, &diagnostics) || diagnostics[0].Code != "E103" {
+		t.Errorf("syntax error = %v, want diagnostic E103", err)
+	}
+
+	_, err = l.Eval(`print 1 + "a";`)
+	var f *fault.Fault
+	if !errors.As(err, &f) || f.Message() != "operands must be two numbers or two strings" {
+		t.Errorf("runtime error = %v", err)
+	}
+
+	if got := eval(t, l, "1;"); got != 1.0 {
+		t.Errorf("Eval after errors = %v, want 1", got)
+	}
+}
+
+func TestReporter(t *testing.T) {
+	l := lox.New()
+	var stderr bytes.Buffer
+	l.SetStderr(&stderr)
+	l.Eval("fun f() { return nil - 1; }\nf();")
+	want := "Error (line 1): operands must be numbers\n    at f (line 1)\n    at script (line 2)\n"
+	if stderr.String() != want {
+		t.Errorf("reported %q, want %q", stderr.String(), want)
+	}
+}
+
+// Errors of runs started by natives are reported once, and only when Lox
+// code does not catch them.
+func TestReporterNestedRun(t *testing.T) {
+	l := lox.New()
+	var stderr bytes.Buffer
+	newLox(t, l)
+	l.SetStderr(&stderr)
+	must(t, l.Register("cb", func() (interface{}, error) { return l.Call("work") }))
+
+	eval(t, l, "fun work() { return nil - 1; } try { cb(); } catch (e) {}")
+	if stderr.Len() != 0 {
+		t.Errorf("reported the caught error %q", stderr.String())
+	}
+
+	l.Eval("cb();")
+	want := "Error (line 1): operands must be numbers\n    at work (line 1)\n    at script (line 1)\n"
+	if stderr.String() != want {
+		t.Errorf("reported %q, want %q", stderr.String(), want)
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	l := lox.New()
+	newLox(t, l)
+	if err := l.SetGlobal("limit", 10); err != nil {
+		t.Fatal(err)
+	}
+	eval(t, l, "var twice = limit * 2;")
+
+	if got, ok := l.GetGlobal("twice"); !ok || got != 20.0 {
+		t.Errorf("GetGlobal(twice) = %v, %v", got, ok)
+	}
+	if _, ok := l.GetGlobal("missing"); ok {
+		t.Error("GetGlobal(missing) found a value")
+	}
+	if err := l.SetGlobal("bad", make(chan int)); err == nil {
+		t.Error("SetGlobal accepted a channel")
+	}
+}
+
+func TestCall(t *testing.T) {
+	l := lox.New()
+	newLox(t, l)
+	eval(t, l, `
+fun add(a, b) { return a + b; }
+class Box { init(v) { this.v = v; } get() { return this.v; } }
+fun unbox(b) { return b.get(); }
+fun fail() { throw "failed"; }`)
+
+	if got, err := l.Call("add", 1, 2.5); err != nil || got != 3.5 {
+		t.Errorf("Call(add) = %v, %v", got, err)
+	}
+
+	box, err := l.Call("Box", []interface{}{"x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := l.Call("unbox", box); err != nil || !reflect.DeepEqual(got, []interface{}{"x"}) {
+		t.Errorf("Call(unbox) = %v, %v", got, err)
+	}
+
+	if _, err := l.Call("add", 1); err == nil {
+		t.Error("Call with too few arguments succeeded")
+	}
+	if _, err := l.Call("missing"); err == nil {
+		t.Error("Call of an undefined function succeeded")
+	}
+	if _, err := l.Call("fail"); err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("Call(fail) = %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	l := lox.New()
+	stdout := newLox(t, l)
+	must(t, l.Register("repeat", strings.Repeat))
+	must(t, l.Register("sum", func(xs ...float64) float64 {
+		total := 0.0
+		for _, x := range xs {
+			total += x
+		}
+		return total
+	}))
+	must(t, l.Register("check", func(ok bool) error {
+		if !ok {
+			return errors.New("check failed")
+		}
+		return nil
+	}))
+	must(t, l.Register("pair", lox.Native{Min: 1, Max: 2, Fn: func(args []interface{}) (interface{}, error) {
+		return args, nil
+	}}))
+	must(t, l.RegisterIn("math", "sqrt", math.Sqrt))
+
+	eval(t, l, `
+print repeat("ab", 3);
+print sum() + sum(1, 2, 3);
+print pair(1);
+print pair(1, "b");
+print math.sqrt(16);
+try { check(false); } catch (e) { print e.message; }
+try { repeat("ab", 1.5); } catch (e) { print e.message; }
+try { pair(); } catch (e) { print e.message; }`)
+
+	want := `ababab
+6
+[1]
+[1, "b"]
+4
+check failed
+argument 2 to repeat must be an integer
+expected 1 to 2 arguments but got 0
+`
+	if got := stdout.String(); got != want {
+		t.Errorf("printed:\n%s\nwant:\n%s", got, want)
+	}
+
+	if err := l.Register("bad", 1); err == nil {
+		t.Error("Register accepted a number")
+	}
+	if err := l.RegisterIn("repeat", "x", math.Abs); err == nil {
+		t.Error("RegisterIn accepted a function as namespace")
+	}
+}
+
+// Errors of runs a native starts reach Lox as they were raised.
+func TestRegisterCallError(t *testing.T) {
+	l := lox.New()
+	stdout := newLox(t, l)
+	must(t, l.Register("cb", func() (interface{}, error) { return l.Call("work") }))
+
+	_, err := l.Eval(`fun work() { return nil.x; }
+try { cb(); } catch (e) { print e.message; print e.line; }
+cb();`)
+	want := "only instances have properties\n1\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("printed %q, want %q", got, want)
+	}
+
+	var f *fault.Fault
+	if !errors.As(err, &f) || f.Error() != "Error (line 1): only instances have properties" {
+		t.Fatalf("Eval = %v", err)
+	}
+	trace := []fault.Frame{{Function: "work", Line: 1}, {Function: "script", Line: 3}}
+	if !reflect.DeepEqual(f.Trace(), trace) {
+		t.Errorf("trace = %v, want %v", f.Trace(), trace)
+	}
+}
+
+func TestRegisterClass(t *testing.T) {
+	l := lox.New()
+	stdout := newLox(t, l)
+	l.RegisterClass("Counter", lox.Class{
+		Methods: map[string]lox.Method{
+			"init": {Min: 1, Max: 1, Fn: func(this *lox.Object, args []interface{}) (interface{}, error) {
+				this.SetData(args[0].(float64))
+				return nil, nil
+			}},
+			"add": {Min: 0, Max: 1, Fn: func(this *lox.Object, args []interface{}) (interface{}, error) {
+				step := 1.0
+				if len(args) == 1 {
+					step = args[0].(float64)
+				}
+				this.SetData(this.Data().(float64) + step)
+				return this.Data(), nil
+			}},
+		},
+		Fields: map[string]lox.Field{
+			"value": {Get: func(this *lox.Object) (interface{}, error) {
+				return this.Data(), nil
+			}},
+			"label": {
+				Get: func(this *lox.Object) (interface{}, error) {
+					label, _ := this.Get("_label")
+					return label, nil
+				},
+				Set: func(this *lox.Object, value interface{}) error {
+					return this.Set("_label", fmt.Sprint(this.Class(), ":", value))
+				},
+			},
+		},
+	})
+
+	eval(t, l, `
+var c = Counter(1);
+c.add();
+print c.add(5);
+print c.value;
+c.label = "x";
+print c.label;
+class Twice < Counter {
+  add() { super.add(); return super.add(); }
+}
+var d = Twice(0);
+print d.add();
+d.label = 1;
+print d.label;
+try { c.value = 2; } catch (e) { print e.message; }`)
+
+	want := `7
+7
+Counter:x
+2
+Twice:1
+cannot assign to read-only field value
+`
+	if got := stdout.String(); got != want {
+		t.Errorf("printed:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	dir := t.TempDir()
+	host := filepath.Join(dir, "host.lox")
+	must(t, os.WriteFile(host, []byte(`var name = "host";`), 0644))
+	bad := filepath.Join(dir, "bad.lox")
+	must(t, os.WriteFile(bad, []byte("nameserver 10.0.0.1\n"), 0644))
+
+	l := lox.NewSandbox(lox.AllowOnly("math", "echo"))
+	stdout := newLox(t, l)
+	var stderr bytes.Buffer
+	l.SetStderr(&stderr)
+	must(t, l.RegisterIn("math", "abs", math.Abs))
+	must(t, l.RegisterIn("os", "getenv", func(string) string { return "secret" }))
+	must(t, l.Register("echo", func(s string) string { return s }))
+
+	eval(t, l, fmt.Sprintf(`
+print math.abs(-2);
+print echo("allowed");
+try { os.getenv("HOME"); } catch (e) { print e.message; }
+try { clock(); } catch (e) { print e.message; }
+try { import h from %q; } catch (e) { print e.message; }
+try { import b from %q; } catch (e) { print e.message; }`, host, bad))
+
+	want := `2
+allowed
+os.getenv is not allowed by the sandbox policy
+clock is not allowed by the sandbox policy
+import is not allowed by the sandbox policy
+import is not allowed by the sandbox policy
+`
+	if got := stdout.String(); got != want {
+		t.Errorf("printed:\n%s\nwant:\n%s", got, want)
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("reported %q", stderr.String())
+	}
+
+	l = lox.NewSandbox(lox.Deny("clock"))
+	stdout = newLox(t, l)
+	if _, err := l.Eval("clock();"); err == nil {
+		t.Error("denied clock was called")
+	}
+	eval(t, l, fmt.Sprintf("import h from %q; print h.name;", host))
+	if got := stdout.String(); got != "host\n" {
+		t.Errorf("printed %q, want %q", got, "host\n")
+	}
+}
+
+func TestStepLimit(t *testing.T) {
+	l := lox.New()
+	newLox(t, l)
+	l.SetStepLimit(1000)
+
+	_, err := l.Eval("var caught = false; try { while (true) {} } catch (e) { caught = true; }")
+	var aborted *lox.Aborted
+	if !errors.As(err, &aborted) || !errors.Is(err, lox.ErrStepLimit) {
+		t.Fatalf("Eval = %v, want the step limit", err)
+	}
+	if caught, _ := l.GetGlobal("caught"); caught != false {
+		t.Error("Lox code caught the abort")
+	}
+
+	if got := eval(t, l, "var n = 0; while (n < 100) n = n + 1; n;"); got != 100.0 {
+		t.Errorf("Eval after abort = %v, want 100", got)
+	}
+}
+
+func TestTimeoutAndContext(t *testing.T) {
+	l := lox.New()
+	newLox(t, l)
+	l.SetTimeout(10 * time.Millisecond)
+	if _, err := l.Eval("while (true) {}"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Eval = %v, want the deadline", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l = lox.New()
+	newLox(t, l)
+	l.SetContext(ctx)
+	if _, err := l.Eval("fun f() {} f();"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Eval = %v, want the cancellation", err)
+	}
+}
+
+// Natives calling back into Lox run inside the budget of the script calling
+// them instead of starting a new one.
+func TestReentrantCall(t *testing.T) {
+	l := lox.New()
+	newLox(t, l)
+	l.SetStepLimit(1000)
+	must(t, l.Register("cb", func() (interface{}, error) { return l.Call("work") }))
+	_, err := l.Eval("fun work() { return 1; } var n = 0; while (n < 100000) { cb(); n = n + 1; }")
+	if !errors.Is(err, lox.ErrStepLimit) {
+		t.Errorf("Eval = %v, want the step limit", err)
+	}
+	if n, _ := l.GetGlobal("n"); n.(float64) >= 1000 {
+		t.Errorf("the loop ran %v times", n)
+	}
+
+	l = lox.New()
+	newLox(t, l)
+	l.SetTimeout(2 * time.Second)
+	must(t, l.Register("cb", func() (interface{}, error) { return l.Call("work") }))
+	if _, err := l.Eval("fun work() { return 1; } for (var i = 0; i < 5; i = i + 1) cb();"); err != nil {
+		t.Errorf("Eval = %v", err)
+	}
+
+	l = lox.New()
+	newLox(t, l)
+	l.SetStepLimit(50)
+	must(t, l.Register("cb", func() (interface{}, error) { return l.Call("work") }))
+	_, err = l.Eval("fun work() { while (true) {} } var caught = false; try { cb(); } catch (e) { caught = true; }")
+	if !errors.Is(err, lox.ErrStepLimit) {
+		t.Errorf("Eval = %v, want the step limit", err)
+	}
+	if caught, _ := l.GetGlobal("caught"); caught != false {
+		t.Error("Lox code caught the abort of a nested run")
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	l := lox.New()
+	stdout := newLox(t, l)
+	l.SetMaxDepth(10)
+	eval(t, l, `
+fun down(n) { if (n == 0) return 0; return down(n - 1) + 1; }
+print down(8);
+try { down(20); } catch (e) { print e.message; }`)
+
+	if got := stdout.String(); got != "8\nstack overflow\n" {
+		t.Errorf("printed %q", got)
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
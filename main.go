@@ -1,18 +1,32 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"os"
+	"path/filepath"
 
 	"github.com/Shri333/golox/run"
 )
 
 func main() {
-	if len(os.Args) > 2 {
-		log.Fatal("Usage golox [script]")
-	} else if len(os.Args) == 2 {
-		run.RunFile(os.Args[1])
+	bytecode := flag.Bool("vm", false, "run scripts on the bytecode vm instead of the tree-walker")
+	path := flag.String("path", os.Getenv("LOXPATH"), "list of directories searched for imported modules")
+	format := flag.String("diagnostics", run.FORMAT_TEXT, "format of errors written to stderr: text, json or sarif")
+	flag.Parse()
+
+	switch *format {
+	case run.FORMAT_TEXT, run.FORMAT_JSON, run.FORMAT_SARIF:
+	default:
+		log.Fatalf("unknown diagnostics format %q", *format)
+	}
+
+	search := filepath.SplitList(*path)
+	if flag.NArg() > 1 {
+		log.Fatal("Usage golox [-vm] [-path dirs] [-diagnostics format] [script]")
+	} else if flag.NArg() == 1 {
+		run.RunFile(flag.Arg(0), *bytecode, search, *format)
 	} else {
-		run.RunPrompt()
+		run.RunPrompt(search, *format)
 	}
 }
@@ -4,12 +4,19 @@ import "github.com/Shri333/golox/scanner"
 
 type Expr interface {
 	Accept(v ExprVisitor) interface{}
+	Span() scanner.Span
+}
+
+type Local struct {
+	Depth int
+	Slot  int
 }
 
 type BinaryExpr struct {
 	Left     Expr
 	Operator *scanner.Token
 	Right    Expr
+	node
 }
 
 func (b *BinaryExpr) Accept(v ExprVisitor) interface{} {
@@ -18,6 +25,7 @@ func (b *BinaryExpr) Accept(v ExprVisitor) interface{} {
 
 type GroupingExpr struct {
 	Expression Expr
+	node
 }
 
 func (g *GroupingExpr) Accept(v ExprVisitor) interface{} {
@@ -26,6 +34,7 @@ func (g *GroupingExpr) Accept(v ExprVisitor) interface{} {
 
 type LiteralExpr struct {
 	Value interface{}
+	node
 }
 
 func (l *LiteralExpr) Accept(v ExprVisitor) interface{} {
@@ -35,6 +44,7 @@ func (l *LiteralExpr) Accept(v ExprVisitor) interface{} {
 type UnaryExpr struct {
 	Operator *scanner.Token
 	Right    Expr
+	node
 }
 
 func (u *UnaryExpr) Accept(v ExprVisitor) interface{} {
@@ -42,7 +52,9 @@ func (u *UnaryExpr) Accept(v ExprVisitor) interface{} {
 }
 
 type VariableExpr struct {
-	Name *scanner.Token
+	Name  *scanner.Token
+	Local *Local
+	node
 }
 
 func (v *VariableExpr) Accept(v_ ExprVisitor) interface{} {
@@ -52,6 +64,8 @@ func (v *VariableExpr) Accept(v_ ExprVisitor) interface{} {
 type AssignExpr struct {
 	Name  *scanner.Token
 	Value Expr
+	Local *Local
+	node
 }
 
 func (a *AssignExpr) Accept(v ExprVisitor) interface{} {
@@ -62,6 +76,7 @@ type LogicalExpr struct {
 	Left     Expr
 	Operator *scanner.Token
 	Right    Expr
+	node
 }
 
 func (l *LogicalExpr) Accept(v ExprVisitor) interface{} {
@@ -72,6 +87,7 @@ type CallExpr struct {
 	Callee    Expr
 	Paren     scanner.Token
 	Arguments []Expr
+	node
 }
 
 func (c *CallExpr) Accept(v ExprVisitor) interface{} {
@@ -81,6 +97,7 @@ func (c *CallExpr) Accept(v ExprVisitor) interface{} {
 type GetExpr struct {
 	Object Expr
 	Name   *scanner.Token
+	node
 }
 
 func (g *GetExpr) Accept(v ExprVisitor) interface{} {
@@ -91,6 +108,7 @@ type SetExpr struct {
 	Object Expr
 	Name   *scanner.Token
 	Value  Expr
+	node
 }
 
 func (s *SetExpr) Accept(v ExprVisitor) interface{} {
@@ -99,6 +117,8 @@ func (s *SetExpr) Accept(v ExprVisitor) interface{} {
 
 type ThisExpr struct {
 	Keyword *scanner.Token
+	Local   *Local
+	node
 }
 
 func (t *ThisExpr) Accept(v ExprVisitor) interface{} {
@@ -108,8 +128,86 @@ func (t *ThisExpr) Accept(v ExprVisitor) interface{} {
 type SuperExpr struct {
 	Keyword *scanner.Token
 	Method  *scanner.Token
+	Local   *Local
+	node
 }
 
 func (s *SuperExpr) Accept(v ExprVisitor) interface{} {
 	return v.VisitSuperExpr(s)
 }
+
+type FunctionExpr struct {
+	Keyword *scanner.Token
+	Params  []*scanner.Token
+	Body    *BlockStmt
+	node
+}
+
+func (f *FunctionExpr) Accept(v ExprVisitor) interface{} {
+	return v.VisitFunctionExpr(f)
+}
+
+type ListExpr struct {
+	Bracket  *scanner.Token
+	Elements []Expr
+	node
+}
+
+func (l *ListExpr) Accept(v ExprVisitor) interface{} {
+	return v.VisitListExpr(l)
+}
+
+type IndexGetExpr struct {
+	Object  Expr
+	Bracket *scanner.Token
+	Index   Expr
+	node
+}
+
+func (i *IndexGetExpr) Accept(v ExprVisitor) interface{} {
+	return v.VisitIndexGetExpr(i)
+}
+
+type IndexSetExpr struct {
+	Object  Expr
+	Bracket *scanner.Token
+	Index   Expr
+	Value   Expr
+	node
+}
+
+func (i *IndexSetExpr) Accept(v ExprVisitor) interface{} {
+	return v.VisitIndexSetExpr(i)
+}
+
+type MapExpr struct {
+	Brace  *scanner.Token
+	Keys   []Expr
+	Values []Expr
+	node
+}
+
+func (m *MapExpr) Accept(v ExprVisitor) interface{} {
+	return v.VisitMapExpr(m)
+}
+
+// InterpolationExpr joins the string forms of its parts, the literal
+// segments of a string and the expressions interpolated between them.
+type InterpolationExpr struct {
+	Parts []Expr
+	node
+}
+
+func (i *InterpolationExpr) Accept(v ExprVisitor) interface{} {
+	return v.VisitInterpolationExpr(i)
+}
+
+// node is embedded in every Expr and Stmt to record the source it was
+// parsed from.
+type node struct {
+	span scanner.Span
+}
+
+func (n *node) Span() scanner.Span {
+	return n.span
+}
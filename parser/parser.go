@@ -2,51 +2,126 @@ package parser
 
 import (
 	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/Shri333/golox/fault"
 	"github.com/Shri333/golox/scanner"
 )
 
+// diagnostic codes
+const (
+	E_EXPECTED_EXPRESSION = "E101"
+	E_EXPECTED_TOKEN      = "E102"
+	E_EXPECTED_NAME       = "E103"
+	E_INVALID_ASSIGNMENT  = "E104"
+	E_TOO_MANY            = "E105"
+	E_MODULE_NAME         = "E106"
+	E_TOO_DEEP            = "E107"
+)
+
+// MAX_NESTING bounds how deeply statements and expressions may nest, so that
+// the passes walking the tree recursively cannot run out of Go stack.
+const MAX_NESTING = 256
+
+// MAX_DEPTH is the default limit on nested calls of both backends, counting
+// the script itself, so that they overflow at the same depth. A call takes
+// about a kilobyte of Go stack in the tree-walker, and tens of kilobytes in
+// code nested up to MAX_NESTING, so the limit stays well below what would
+// exhaust the Go stack.
+const MAX_DEPTH = 1 << 12
+
 type Parser struct {
-	tokens  []scanner.Token
-	current int
-	err     error
+	tokens   []scanner.Token
+	current  int
+	file     string
+	errs     fault.Diagnostics
+	reporter fault.Reporter
+	depth    int
 }
 
-func NewParser(tokens []scanner.Token) *Parser {
-	return &Parser{tokens, 0, nil}
+// tooDeep is the error of a construct nested more than MAX_NESTING levels,
+// which ends parsing rather than being skipped like other errors.
+type tooDeep struct {
+	*fault.Diagnostic
 }
 
-func (p *Parser) Parse() ([]Stmt, error) {
-	stmts := []Stmt{}
+// NewParser returns a parser for tokens scanned from file, which is only
+// used to locate diagnostics.
+func NewParser(tokens []scanner.Token, file string, reporter fault.Reporter) *Parser {
+	return &Parser{tokens, 0, file, nil, reporter, 0}
+}
+
+// Parse returns the statements it could parse and every error it found,
+// skipping to the next statement after each one.
+func (p *Parser) Parse() ([]Stmt, fault.Diagnostics) {
+	stmts := p.declarations()
+	if p.errs != nil {
+		p.reporter.Report(p.errs)
+		return stmts, p.errs
+	}
+
+	return stmts, nil
+}
+
+func (p *Parser) declarations() (stmts []Stmt) {
+	defer func() {
+		if r := recover(); r != nil {
+			t, ok := r.(tooDeep)
+			if !ok {
+				panic(r)
+			}
+			p.errs = append(p.errs, t.Diagnostic)
+		}
+	}()
+
+	stmts = []Stmt{}
 	for p.tokens[p.current].TokenType != scanner.EOF {
 		stmts = append(stmts, p.declaration())
 	}
 
-	return stmts, p.err
+	return stmts
+}
+
+// nest enters a level of nesting and returns the function leaving it.
+func (p *Parser) nest() func() {
+	p.depth++
+	if p.depth > MAX_NESTING {
+		panic(tooDeep{p.diagnose(p.tokens[p.current], E_TOO_DEEP, "too deeply nested")})
+	}
+
+	return func() { p.depth-- }
 }
 
 func (p *Parser) declaration() Stmt {
 	defer p.synchronize()
+	defer p.nest()()
 
 	if p.match(scanner.VAR) {
 		return p.varDeclaration()
 	}
 
-	if p.match(scanner.FUN) {
-		return p.funDeclaration("function")
+	if p.tokens[p.current].TokenType == scanner.FUN && p.tokens[p.current+1].TokenType != scanner.LEFT_PAREN {
+		p.current++
+		return p.funDeclaration("function", p.current-1)
 	}
 
 	if p.match(scanner.CLASS) {
 		return p.classDeclaration()
 	}
 
+	if p.match(scanner.IMPORT) {
+		return p.importDeclaration()
+	}
+
 	return p.statement()
 }
 
 func (p *Parser) varDeclaration() *VarStmt {
+	start := p.current - 1
 	if !p.match(scanner.IDENTIFIER) {
-		panic(fault.NewFault(p.tokens[p.current].Line, "expected variable name"))
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_NAME, "expected variable name"))
 	}
 
 	name := p.tokens[p.current-1]
@@ -56,87 +131,127 @@ func (p *Parser) varDeclaration() *VarStmt {
 	}
 
 	if !p.match(scanner.SEMICOLON) {
-		panic(fault.NewFault(p.tokens[p.current].Line, "expected ';' after variable declaration"))
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected ';' after variable declaration"))
 	}
 
-	return &VarStmt{&name, initializer}
+	return &VarStmt{&name, initializer, p.node(start)}
 }
 
-func (p *Parser) funDeclaration(kind string) *FunStmt {
+func (p *Parser) funDeclaration(kind string, start int) *FunStmt {
 	if !p.match(scanner.IDENTIFIER) {
 		message := fmt.Sprintf("expected %s name", kind)
-		panic(fault.NewFault(p.tokens[p.current].Line, message))
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_NAME, message))
 	}
 	name := p.tokens[p.current-1]
 
 	if !p.match(scanner.LEFT_PAREN) {
 		message := fmt.Sprintf("expected '(' after %s name", kind)
-		panic(fault.NewFault(p.tokens[p.current].Line, message))
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, message))
 	}
 
+	params, body := p.functionBody(kind)
+	return &FunStmt{&name, params, body, p.node(start)}
+}
+
+func (p *Parser) functionBody(kind string) ([]*scanner.Token, *BlockStmt) {
 	params := []*scanner.Token{}
 	if p.tokens[p.current].TokenType != scanner.RIGHT_PAREN && p.tokens[p.current].TokenType != scanner.EOF {
 		if !p.match(scanner.IDENTIFIER) {
 			message := fmt.Sprintf("expected parameter name at %s", p.tokens[p.current].Lexeme)
-			panic(fault.NewFault(p.tokens[p.current].Line, message))
+			panic(p.diagnose(p.tokens[p.current], E_EXPECTED_NAME, message))
 		}
 		params = append(params, &p.tokens[p.current-1])
 		for p.match(scanner.COMMA) {
 			if !p.match(scanner.IDENTIFIER) {
 				message := fmt.Sprintf("expected parameter name at %s", p.tokens[p.current].Lexeme)
-				panic(fault.NewFault(p.tokens[p.current].Line, message))
+				panic(p.diagnose(p.tokens[p.current], E_EXPECTED_NAME, message))
 			}
 			params = append(params, &p.tokens[p.current-1])
 			if len(params) > 255 {
-				panic(fault.NewFault(p.tokens[p.current].Line, "cannot have more than 255 parameters"))
+				panic(p.diagnose(p.tokens[p.current], E_TOO_MANY, "cannot have more than 255 parameters"))
 			}
 		}
 	}
 
 	if !p.match(scanner.RIGHT_PAREN) {
-		panic(fault.NewFault(p.tokens[p.current].Line, "expected ')' after parameter list"))
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected ')' after parameter list"))
 	}
 
 	if !p.match(scanner.LEFT_BRACE) {
 		message := fmt.Sprintf("expected '{' before %s body", kind)
-		panic(fault.NewFault(p.tokens[p.current].Line, message))
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, message))
 	}
 
-	return &FunStmt{&name, params, p.blockStatement()}
+	return params, p.blockStatement()
 }
 
 func (p *Parser) classDeclaration() *ClassStmt {
+	start := p.current - 1
 	if !p.match(scanner.IDENTIFIER) {
-		panic(fault.NewFault(p.tokens[p.current].Line, "expected class name"))
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_NAME, "expected class name"))
 	}
 	name := p.tokens[p.current-1]
 
 	var super *VariableExpr
 	if p.match(scanner.LESS) {
 		if !p.match(scanner.IDENTIFIER) {
-			panic(fault.NewFault(p.tokens[p.current].Line, "expected superclass name after '<'"))
+			panic(p.diagnose(p.tokens[p.current], E_EXPECTED_NAME, "expected superclass name after '<'"))
 		}
 		superName := p.tokens[p.current-1]
-		super = &VariableExpr{&superName}
+		super = &VariableExpr{&superName, nil, p.node(p.current - 1)}
 	}
 
 	if !p.match(scanner.LEFT_BRACE) {
-		panic(fault.NewFault(p.tokens[p.current].Line, "expected '{' before class body"))
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected '{' before class body"))
 	}
 
 	methods := []*FunStmt{}
 	for p.tokens[p.current].TokenType != scanner.RIGHT_BRACE && p.tokens[p.current].TokenType != scanner.EOF {
-		methods = append(methods, p.funDeclaration("method"))
+		methods = append(methods, p.funDeclaration("method", p.current))
 	}
 
 	if !p.match(scanner.RIGHT_BRACE) {
-		panic(fault.NewFault(p.tokens[p.current].Line, "expected '}' after class body"))
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected '}' after class body"))
 	}
 
-	return &ClassStmt{&name, super, methods}
+	return &ClassStmt{&name, super, methods, p.node(start)}
+}
+
+func (p *Parser) importDeclaration() *ImportStmt {
+	start := p.current - 1
+	keyword := p.tokens[start]
+	var name *scanner.Token
+	if p.match(scanner.IDENTIFIER) {
+		name = &p.tokens[p.current-1]
+		if !p.match(scanner.IDENTIFIER) || p.tokens[p.current-1].Lexeme != "from" {
+			panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected 'from' after module name"))
+		}
+	}
+
+	if !p.match(scanner.STRING) {
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected module path"))
+	}
+	path := p.tokens[p.current-1]
+
+	if name == nil {
+		base := filepath.Base(path.Literal.(string))
+		base = strings.TrimSuffix(base, filepath.Ext(base))
+		if !scanner.IsIdentifier(base) {
+			message := fmt.Sprintf("cannot name module %s, use 'import NAME from' instead", path.Lexeme)
+			panic(p.diagnose(path, E_MODULE_NAME, message))
+		}
+		name = &scanner.Token{TokenType: scanner.IDENTIFIER, Lexeme: base, Line: path.Line, Column: path.Column}
+	}
+
+	if !p.match(scanner.SEMICOLON) {
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected ';' after import"))
+	}
+
+	return &ImportStmt{&keyword, name, &path, p.node(start)}
 }
 
 func (p *Parser) statement() Stmt {
+	defer p.nest()()
 	if p.match(scanner.PRINT) {
 		return p.printStatement()
 	}
@@ -161,26 +276,44 @@ func (p *Parser) statement() Stmt {
 		return p.returnStatement()
 	}
 
+	if p.match(scanner.BREAK) {
+		return p.breakStatement()
+	}
+
+	if p.match(scanner.CONTINUE) {
+		return p.continueStatement()
+	}
+
+	if p.match(scanner.THROW) {
+		return p.throwStatement()
+	}
+
+	if p.match(scanner.TRY) {
+		return p.tryStatement()
+	}
+
 	return p.exprStatement()
 }
 
 func (p *Parser) printStatement() *PrintStmt {
+	start := p.current - 1
 	expr := p.expression()
 	if !p.match(scanner.SEMICOLON) {
-		panic(fault.NewFault(p.tokens[p.current].Line, "expected ';' after print statement"))
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected ';' after print statement"))
 	}
 
-	return &PrintStmt{expr}
+	return &PrintStmt{expr, p.node(start)}
 }
 
 func (p *Parser) ifStatement() *IfStmt {
+	start := p.current - 1
 	if !p.match(scanner.LEFT_PAREN) {
-		panic(fault.NewFault(p.tokens[p.current].Line, "expected '(' after if"))
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected '(' after if"))
 	}
 
 	condition := p.expression()
 	if !p.match(scanner.RIGHT_PAREN) {
-		panic(fault.NewFault(p.tokens[p.current].Line, "expected ')' after conditional expression"))
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected ')' after conditional expression"))
 	}
 
 	thenBranch := p.statement()
@@ -189,12 +322,13 @@ func (p *Parser) ifStatement() *IfStmt {
 		elseBranch = p.statement()
 	}
 
-	return &IfStmt{condition, thenBranch, elseBranch}
+	return &IfStmt{condition, thenBranch, elseBranch, p.node(start)}
 }
 
 func (p *Parser) forStatement() Stmt {
+	start := p.current - 1
 	if !p.match(scanner.LEFT_PAREN) {
-		panic(fault.NewFault(p.tokens[p.current].Line, "expected '(' after for"))
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected '(' after for"))
 	}
 
 	var initializer Stmt
@@ -211,7 +345,7 @@ func (p *Parser) forStatement() Stmt {
 		condition = p.expression()
 	}
 	if !p.match(scanner.SEMICOLON) {
-		panic(fault.NewFault(p.tokens[p.current].Line, "expected ';' after conditional expression"))
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected ';' after conditional expression"))
 	}
 
 	var increment Expr
@@ -219,95 +353,175 @@ func (p *Parser) forStatement() Stmt {
 		increment = p.expression()
 	}
 	if !p.match(scanner.RIGHT_PAREN) {
-		panic(fault.NewFault(p.tokens[p.current].Line, "expected ')' after for clause"))
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected ')' after for clause"))
 	}
 
 	body := p.statement()
-	if increment != nil {
-		body = &BlockStmt{[]Stmt{body, &ExprStmt{increment}}}
-	}
-
 	if condition == nil {
-		condition = &LiteralExpr{true}
+		condition = &LiteralExpr{true, node{p.tokens[start].Span()}}
 	}
 
-	body = &WhileStmt{condition, body}
+	body = &WhileStmt{condition, body, increment, p.node(start)}
 
 	if initializer != nil {
-		body = &BlockStmt{[]Stmt{initializer, body}}
+		body = &BlockStmt{[]Stmt{initializer, body}, p.node(start)}
 	}
 
 	return body
 }
 
 func (p *Parser) whileStatement() *WhileStmt {
+	start := p.current - 1
 	if !p.match(scanner.LEFT_PAREN) {
-		panic(fault.NewFault(p.tokens[p.current].Line, "expected '(' after while"))
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected '(' after while"))
 	}
 
 	condition := p.expression()
 	if !p.match(scanner.RIGHT_PAREN) {
-		panic(fault.NewFault(p.tokens[p.current].Line, "expected ')' after conditional expression"))
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected ')' after conditional expression"))
 	}
 
-	return &WhileStmt{condition, p.statement()}
+	body := p.statement()
+	return &WhileStmt{condition, body, nil, p.node(start)}
 }
 
 func (p *Parser) blockStatement() *BlockStmt {
+	start := p.current - 1
 	stmts := []Stmt{}
 	for p.tokens[p.current].TokenType != scanner.RIGHT_BRACE && p.tokens[p.current].TokenType != scanner.EOF {
 		stmts = append(stmts, p.declaration())
 	}
 
 	if !p.match(scanner.RIGHT_BRACE) {
-		panic(fault.NewFault(p.tokens[p.current].Line, "expected '}' after block"))
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected '}' after block"))
 	}
 
-	return &BlockStmt{stmts}
+	return &BlockStmt{stmts, p.node(start)}
 }
 
 func (p *Parser) exprStatement() *ExprStmt {
+	start := p.current
 	expr := p.expression()
 	if !p.match(scanner.SEMICOLON) {
-		panic(fault.NewFault(p.tokens[p.current].Line, "expected ';' after expression statement"))
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected ';' after expression statement"))
 	}
 
-	return &ExprStmt{expr}
+	return &ExprStmt{expr, p.node(start)}
 }
 
 func (p *Parser) returnStatement() *ReturnStmt {
-	keyword := p.tokens[p.current-1]
+	start := p.current - 1
+	keyword := p.tokens[start]
 	var value Expr
 	if p.tokens[p.current].TokenType != scanner.SEMICOLON && p.tokens[p.current].TokenType != scanner.EOF {
 		value = p.expression()
 	}
 
 	if !p.match(scanner.SEMICOLON) {
-		panic(fault.NewFault(p.tokens[p.current].Line, "expected ';' after return statement"))
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected ';' after return statement"))
+	}
+
+	return &ReturnStmt{&keyword, value, p.node(start)}
+}
+
+func (p *Parser) breakStatement() *BreakStmt {
+	start := p.current - 1
+	keyword := p.tokens[start]
+	if !p.match(scanner.SEMICOLON) {
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected ';' after break"))
+	}
+
+	return &BreakStmt{&keyword, p.node(start)}
+}
+
+func (p *Parser) continueStatement() *ContinueStmt {
+	start := p.current - 1
+	keyword := p.tokens[start]
+	if !p.match(scanner.SEMICOLON) {
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected ';' after continue"))
+	}
+
+	return &ContinueStmt{&keyword, p.node(start)}
+}
+
+func (p *Parser) throwStatement() *ThrowStmt {
+	start := p.current - 1
+	keyword := p.tokens[start]
+	value := p.expression()
+	if !p.match(scanner.SEMICOLON) {
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected ';' after throw statement"))
+	}
+
+	return &ThrowStmt{&keyword, value, p.node(start)}
+}
+
+func (p *Parser) tryStatement() *TryStmt {
+	start := p.current - 1
+	keyword := p.tokens[start]
+	if !p.match(scanner.LEFT_BRACE) {
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected '{' after try"))
 	}
+	body := p.blockStatement()
 
-	return &ReturnStmt{&keyword, value}
+	var name *scanner.Token
+	var catch *BlockStmt
+	if p.match(scanner.CATCH) {
+		if !p.match(scanner.LEFT_PAREN) {
+			panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected '(' after catch"))
+		}
+		if !p.match(scanner.IDENTIFIER) {
+			panic(p.diagnose(p.tokens[p.current], E_EXPECTED_NAME, "expected exception variable name"))
+		}
+		name = &p.tokens[p.current-1]
+		if !p.match(scanner.RIGHT_PAREN) {
+			panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected ')' after exception variable name"))
+		}
+		if !p.match(scanner.LEFT_BRACE) {
+			panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected '{' before catch body"))
+		}
+		catch = p.blockStatement()
+	}
+
+	var finally *BlockStmt
+	if p.match(scanner.FINALLY) {
+		if !p.match(scanner.LEFT_BRACE) {
+			panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected '{' after finally"))
+		}
+		finally = p.blockStatement()
+	}
+
+	if catch == nil && finally == nil {
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected 'catch' or 'finally' after try block"))
+	}
+
+	return &TryStmt{&keyword, body, name, catch, finally, p.node(start)}
 }
 
 func (p *Parser) expression() Expr {
+	defer p.nest()()
 	return p.assignment()
 }
 
 func (p *Parser) assignment() Expr {
 	expr := p.or()
 	if p.match(scanner.EQUAL) {
+		defer p.nest()()
 		equals := p.tokens[p.current-1]
 		value := p.assignment()
 
 		if variable, ok := expr.(*VariableExpr); ok {
-			return &AssignExpr{variable.Name, value}
+			return &AssignExpr{variable.Name, value, nil, p.extend(expr)}
 		}
 
 		if get, ok := expr.(*GetExpr); ok {
-			return &SetExpr{get.Object, get.Name, value}
+			return &SetExpr{get.Object, get.Name, value, p.extend(expr)}
 		}
 
-		fault.NewFault(equals.Line, "invalid assignment target")
+		if index, ok := expr.(*IndexGetExpr); ok {
+			return &IndexSetExpr{index.Object, index.Bracket, index.Index, value, p.extend(expr)}
+		}
+
+		p.errs = append(p.errs, p.diagnose(equals, E_INVALID_ASSIGNMENT, "invalid assignment target"))
 	}
 
 	return expr
@@ -318,7 +532,7 @@ func (p *Parser) or() Expr {
 	for p.match(scanner.OR) {
 		operator := p.tokens[p.current-1]
 		right := p.and()
-		left = &LogicalExpr{left, &operator, right}
+		left = &LogicalExpr{left, &operator, right, p.extend(left)}
 	}
 
 	return left
@@ -329,7 +543,7 @@ func (p *Parser) and() Expr {
 	for p.match(scanner.AND) {
 		operator := p.tokens[p.current-1]
 		right := p.equality()
-		left = &LogicalExpr{left, &operator, right}
+		left = &LogicalExpr{left, &operator, right, p.extend(left)}
 	}
 
 	return left
@@ -340,7 +554,7 @@ func (p *Parser) equality() Expr {
 	for p.match(scanner.BANG_EQUAL, scanner.EQUAL_EQUAL) {
 		operator := p.tokens[p.current-1]
 		right := p.comparison()
-		left = &BinaryExpr{left, &operator, right}
+		left = &BinaryExpr{left, &operator, right, p.extend(left)}
 	}
 
 	return left
@@ -351,7 +565,7 @@ func (p *Parser) comparison() Expr {
 	for p.match(scanner.GREATER, scanner.GREATER_EQUAL, scanner.LESS, scanner.LESS_EQUAL) {
 		operator := p.tokens[p.current-1]
 		right := p.term()
-		left = &BinaryExpr{left, &operator, right}
+		left = &BinaryExpr{left, &operator, right, p.extend(left)}
 	}
 
 	return left
@@ -362,7 +576,7 @@ func (p *Parser) term() Expr {
 	for p.match(scanner.MINUS, scanner.PLUS) {
 		operator := p.tokens[p.current-1]
 		right := p.factor()
-		left = &BinaryExpr{left, &operator, right}
+		left = &BinaryExpr{left, &operator, right, p.extend(left)}
 	}
 
 	return left
@@ -373,7 +587,7 @@ func (p *Parser) factor() Expr {
 	for p.match(scanner.SLASH, scanner.STAR) {
 		operator := p.tokens[p.current-1]
 		right := p.unary()
-		left = &BinaryExpr{left, &operator, right}
+		left = &BinaryExpr{left, &operator, right, p.extend(left)}
 	}
 
 	return left
@@ -381,9 +595,11 @@ func (p *Parser) factor() Expr {
 
 func (p *Parser) unary() Expr {
 	if p.match(scanner.BANG, scanner.MINUS) {
-		operator := p.tokens[p.current-1]
+		defer p.nest()()
+		start := p.current - 1
+		operator := p.tokens[start]
 		right := p.unary()
-		return &UnaryExpr{&operator, right}
+		return &UnaryExpr{&operator, right, p.node(start)}
 	}
 
 	return p.call()
@@ -394,13 +610,20 @@ func (p *Parser) call() Expr {
 	for {
 		if p.match(scanner.LEFT_PAREN) {
 			args, paren := p.arguments()
-			expr = &CallExpr{expr, paren, args}
+			expr = &CallExpr{expr, paren, args, p.extend(expr)}
 		} else if p.match(scanner.DOT) {
 			if !p.match(scanner.IDENTIFIER) {
-				panic(fault.NewFault(p.tokens[p.current].Line, "expected property name after '.'"))
+				panic(p.diagnose(p.tokens[p.current], E_EXPECTED_NAME, "expected property name after '.'"))
 			}
 			name := p.tokens[p.current-1]
-			expr = &GetExpr{expr, &name}
+			expr = &GetExpr{expr, &name, p.extend(expr)}
+		} else if p.match(scanner.LEFT_BRACKET) {
+			index := p.expression()
+			if !p.match(scanner.RIGHT_BRACKET) {
+				panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected ']' after index"))
+			}
+			bracket := p.tokens[p.current-1]
+			expr = &IndexGetExpr{expr, &bracket, index, p.extend(expr)}
 		} else {
 			break
 		}
@@ -416,66 +639,153 @@ func (p *Parser) arguments() ([]Expr, scanner.Token) {
 		for p.match(scanner.COMMA) {
 			args = append(args, p.expression())
 			if len(args) > 255 {
-				panic(fault.NewFault(p.tokens[p.current].Line, "cannot have more than 255 arguments"))
+				panic(p.diagnose(p.tokens[p.current], E_TOO_MANY, "cannot have more than 255 arguments"))
 			}
 		}
 	}
 
 	if !p.match(scanner.RIGHT_PAREN) {
-		panic(fault.NewFault(p.tokens[p.current].Line, "expected ')' after argument list"))
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected ')' after argument list"))
 	}
 
 	return args, p.tokens[p.current-1]
 }
 
+// interpolation parses a string with expressions interpolated into it,
+// whose first segment starts at start.
+func (p *Parser) interpolation(start int) Expr {
+	parts := []Expr{}
+	for {
+		if segment := p.tokens[p.current-1].Literal.(string); segment != "" {
+			parts = append(parts, &LiteralExpr{segment, p.node(p.current - 1)})
+		}
+		if next := p.tokens[p.current]; next.TokenType == scanner.STRING || next.TokenType == scanner.INTERPOLATION {
+			if strings.HasPrefix(next.Lexeme, "}") {
+				next.Lexeme, next.End = "}", next.Offset+1
+				panic(p.diagnose(next, E_EXPECTED_EXPRESSION, "expected expression inside '${}'"))
+			}
+		}
+		parts = append(parts, p.expression())
+		if p.match(scanner.INTERPOLATION) {
+			continue
+		}
+		if !p.match(scanner.STRING) {
+			panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected '}' after interpolated expression"))
+		}
+		if segment := p.tokens[p.current-1].Literal.(string); segment != "" {
+			parts = append(parts, &LiteralExpr{segment, p.node(p.current - 1)})
+		}
+		return &InterpolationExpr{parts, p.node(start)}
+	}
+}
+
 func (p *Parser) primary() Expr {
 	if p.match(scanner.FALSE) {
-		return &LiteralExpr{false}
+		return &LiteralExpr{false, p.node(p.current - 1)}
 	}
 
 	if p.match(scanner.TRUE) {
-		return &LiteralExpr{true}
+		return &LiteralExpr{true, p.node(p.current - 1)}
 	}
 
 	if p.match(scanner.NIL) {
-		return &LiteralExpr{nil}
+		return &LiteralExpr{nil, p.node(p.current - 1)}
 	}
 
 	if p.match(scanner.NUMBER, scanner.STRING) {
 		value := p.tokens[p.current-1].Literal
-		return &LiteralExpr{value}
+		return &LiteralExpr{value, p.node(p.current - 1)}
+	}
+
+	if p.match(scanner.INTERPOLATION) {
+		return p.interpolation(p.current - 1)
 	}
 
 	if p.match(scanner.IDENTIFIER) {
 		previous := &p.tokens[p.current-1]
-		return &VariableExpr{previous}
+		return &VariableExpr{previous, nil, p.node(p.current - 1)}
 	}
 
 	if p.match(scanner.THIS) {
 		previous := &p.tokens[p.current-1]
-		return &ThisExpr{previous}
+		return &ThisExpr{previous, nil, p.node(p.current - 1)}
 	}
 
 	if p.match(scanner.SUPER) {
-		keyword := p.tokens[p.current-1]
+		start := p.current - 1
+		keyword := p.tokens[start]
 		if !p.match(scanner.DOT) || !p.match(scanner.IDENTIFIER) {
-			panic(fault.NewFault(p.tokens[p.current].Line, "expected property access after 'super'"))
+			panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected property access after 'super'"))
 		}
 		method := p.tokens[p.current-1]
-		return &SuperExpr{&keyword, &method}
+		return &SuperExpr{&keyword, &method, nil, p.node(start)}
+	}
+
+	if p.match(scanner.FUN) {
+		start := p.current - 1
+		keyword := p.tokens[start]
+		if !p.match(scanner.LEFT_PAREN) {
+			panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected '(' after fun"))
+		}
+		params, body := p.functionBody("function")
+		return &FunctionExpr{&keyword, params, body, p.node(start)}
+	}
+
+	if p.match(scanner.LEFT_BRACKET) {
+		start := p.current - 1
+		elements := []Expr{}
+		if p.tokens[p.current].TokenType != scanner.RIGHT_BRACKET && p.tokens[p.current].TokenType != scanner.EOF {
+			elements = append(elements, p.expression())
+			for p.match(scanner.COMMA) {
+				elements = append(elements, p.expression())
+			}
+		}
+
+		if !p.match(scanner.RIGHT_BRACKET) {
+			panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected ']' after list elements"))
+		}
+		bracket := p.tokens[p.current-1]
+		return &ListExpr{&bracket, elements, p.node(start)}
+	}
+
+	if p.match(scanner.LEFT_BRACE) {
+		start := p.current - 1
+		keys, values := []Expr{}, []Expr{}
+		if p.tokens[p.current].TokenType != scanner.RIGHT_BRACE && p.tokens[p.current].TokenType != scanner.EOF {
+			keys, values = p.entry(keys, values)
+			for p.match(scanner.COMMA) {
+				keys, values = p.entry(keys, values)
+			}
+		}
+
+		if !p.match(scanner.RIGHT_BRACE) {
+			panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected '}' after map entries"))
+		}
+		brace := p.tokens[p.current-1]
+		return &MapExpr{&brace, keys, values, p.node(start)}
 	}
 
 	if p.match(scanner.LEFT_PAREN) {
+		start := p.current - 1
 		e := p.expression()
 		if !p.match(scanner.RIGHT_PAREN) {
 			message := fmt.Sprintf("expected ')' after '%s'", p.tokens[p.current-1].Lexeme)
-			panic(fault.NewFault(p.tokens[p.current].Line, message))
+			panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, message))
 		}
-		return &GroupingExpr{e}
+		return &GroupingExpr{e, p.node(start)}
 	}
 
 	message := fmt.Sprintf("expected expression at '%s'", p.tokens[p.current].Lexeme)
-	panic(fault.NewFault(p.tokens[p.current].Line, message))
+	panic(p.diagnose(p.tokens[p.current], E_EXPECTED_EXPRESSION, message))
+}
+
+func (p *Parser) entry(keys []Expr, values []Expr) ([]Expr, []Expr) {
+	keys = append(keys, p.expression())
+	if !p.match(scanner.COLON) {
+		panic(p.diagnose(p.tokens[p.current], E_EXPECTED_TOKEN, "expected ':' after map key"))
+	}
+
+	return keys, append(values, p.expression())
 }
 
 func (p *Parser) match(types ...int) bool {
@@ -494,9 +804,34 @@ func (p *Parser) match(types ...int) bool {
 	return false
 }
 
+// node spans from the token at start to the last token consumed.
+func (p *Parser) node(start int) node {
+	return node{p.tokens[start].Span().To(p.tokens[p.current-1].Span())}
+}
+
+// extend spans from the start of from to the last token consumed.
+func (p *Parser) extend(from Expr) node {
+	return node{from.Span().To(p.tokens[p.current-1].Span())}
+}
+
+// diagnose returns an error diagnostic underlining token t.
+func (p *Parser) diagnose(t scanner.Token, code string, message string) *fault.Diagnostic {
+	span := t.Span()
+	if span.EndLine != span.Line {
+		first := t.Lexeme[:strings.IndexByte(t.Lexeme, '\n')]
+		span.EndColumn = span.Column + utf8.RuneCountInString(first)
+	}
+
+	return fault.NewDiagnostic(code, p.file, span.Line, span.Column, span.EndColumn, message)
+}
+
 func (p *Parser) synchronize() {
 	if r := recover(); r != nil {
-		defer func() { p.err = r.(error) }()
+		d, ok := r.(*fault.Diagnostic)
+		if !ok {
+			panic(r)
+		}
+		defer func() { p.errs = append(p.errs, d) }()
 
 		if p.tokens[p.current].TokenType != scanner.EOF {
 			p.current++
@@ -524,6 +859,12 @@ func (p *Parser) synchronize() {
 				return
 			case scanner.RETURN:
 				return
+			case scanner.THROW:
+				return
+			case scanner.TRY:
+				return
+			case scanner.IMPORT:
+				return
 			}
 
 			p.current++
@@ -0,0 +1,141 @@
+package parser_test
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Shri333/golox/fault"
+	"github.com/Shri333/golox/parser"
+	"github.com/Shri333/golox/scanner"
+)
+
+// diagnostic is the part of a fault.Diagnostic that locates it.
+type diagnostic struct {
+	code      string
+	line      int
+	column    int
+	endColumn int
+}
+
+func parse(t *testing.T, source string) ([]parser.Stmt, []diagnostic) {
+	t.Helper()
+	reporter := fault.NewReporter(io.Discard)
+	s := scanner.NewScanner(source, "test.lox", reporter)
+	if errs := s.ScanTokens(); errs != nil {
+		t.Fatalf("scan(%q): %v", source, errs)
+	}
+
+	stmts, errs := parser.NewParser(s.Tokens, "test.lox", reporter).Parse()
+	var located []diagnostic
+	for _, d := range errs {
+		located = append(located, diagnostic{d.Code, d.Line, d.Column, d.EndColumn})
+	}
+
+	return stmts, located
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []diagnostic
+	}{
+		{"print 1 + 2;", nil},
+		{"print ;", []diagnostic{{parser.E_EXPECTED_EXPRESSION, 1, 7, 8}}},
+		{"print 1", []diagnostic{{parser.E_EXPECTED_TOKEN, 1, 8, 8}}},
+		{"var = 1;", []diagnostic{{parser.E_EXPECTED_NAME, 1, 5, 6}}},
+		{"fun 1() {}", []diagnostic{{parser.E_EXPECTED_NAME, 1, 5, 6}}},
+		{"1 + 2 = 3;", []diagnostic{{parser.E_INVALID_ASSIGNMENT, 1, 7, 8}}},
+		{"f(" + strings.Repeat("1, ", 255) + "1);", []diagnostic{{parser.E_TOO_MANY, 1, 769, 770}}},
+		{`import "my-lib";`, []diagnostic{{parser.E_MODULE_NAME, 1, 8, 16}}},
+		{`import "lib/class.lox";`, []diagnostic{{parser.E_MODULE_NAME, 1, 8, 23}}},
+		{"print " + strings.Repeat("(", 300) + "1" + strings.Repeat(")", 300) + ";", []diagnostic{{parser.E_TOO_DEEP, 1, 261, 262}}},
+		{"var = 1;\nprint ;\nprint 2 print 3;", []diagnostic{
+			{parser.E_EXPECTED_NAME, 1, 5, 6},
+			{parser.E_EXPECTED_EXPRESSION, 2, 7, 8},
+			{parser.E_EXPECTED_TOKEN, 3, 9, 14},
+		}},
+	}
+
+	for _, test := range tests {
+		if _, got := parse(t, test.source); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parse(%.40q) diagnostics = %v, want %v", test.source, got, test.want)
+		}
+	}
+}
+
+// span is a scanner.Span in the order of its fields.
+type span struct {
+	offset, end, line, column, endLine, endColumn int
+}
+
+func spanOf(s scanner.Span) span {
+	return span{s.Offset, s.End, s.Line, s.Column, s.EndLine, s.EndColumn}
+}
+
+func TestNodeSpans(t *testing.T) {
+	stmts, errs := parse(t, "print 1 +\n  f(x);\nvar a = [1, 2];\n  {\n  a[0] = \"é\";\n}")
+	if errs != nil {
+		t.Fatal(errs)
+	}
+
+	print := stmts[0].(*parser.PrintStmt)
+	block := stmts[2].(*parser.BlockStmt)
+	assign := block.Statements[0].(*parser.ExprStmt)
+	tests := []struct {
+		name string
+		node interface{ Span() scanner.Span }
+		want span
+	}{
+		{"print", print, span{0, 17, 1, 1, 2, 8}},
+		{"binary", print.Expression, span{6, 16, 1, 7, 2, 7}},
+		{"call", print.Expression.(*parser.BinaryExpr).Right, span{12, 16, 2, 3, 2, 7}},
+		{"var", stmts[1], span{18, 33, 3, 1, 3, 16}},
+		{"list", stmts[1].(*parser.VarStmt).Initializer, span{26, 32, 3, 9, 3, 15}},
+		{"block", block, span{36, 54, 4, 3, 6, 2}},
+		{"index assignment", assign.Expression, span{40, 51, 5, 3, 5, 13}},
+	}
+
+	for _, test := range tests {
+		if got := spanOf(test.node.Span()); got != test.want {
+			t.Errorf("span of %s = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	stmts, errs := parse(t, `print "a ${b} c ${"d ${e}"}${1 + 2}";`)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+
+	interpolation, ok := stmts[0].(*parser.PrintStmt).Expression.(*parser.InterpolationExpr)
+	if !ok {
+		t.Fatalf("printed %T, want an interpolation", stmts[0].(*parser.PrintStmt).Expression)
+	}
+	var kinds []string
+	for _, part := range interpolation.Parts {
+		kinds = append(kinds, strings.TrimPrefix(reflect.TypeOf(part).String(), "*parser."))
+	}
+	want := []string{"LiteralExpr", "VariableExpr", "LiteralExpr", "InterpolationExpr", "BinaryExpr"}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Errorf("parts = %v, want %v", kinds, want)
+	}
+	if span := interpolation.Span(); span.Offset != 6 || span.End != 36 {
+		t.Errorf("span = %v, want offsets 6 to 36", span)
+	}
+
+	tests := []struct {
+		source string
+		want   []diagnostic
+	}{
+		{`print "${}";`, []diagnostic{{parser.E_EXPECTED_EXPRESSION, 1, 10, 11}}},
+		{`print "${a b}";`, []diagnostic{{parser.E_EXPECTED_TOKEN, 1, 12, 13}}},
+	}
+	for _, test := range tests {
+		if _, got := parse(t, test.source); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parse(%q) diagnostics = %v, want %v", test.source, got, test.want)
+		}
+	}
+}
@@ -4,10 +4,12 @@ import "github.com/Shri333/golox/scanner"
 
 type Stmt interface {
 	Accept(v StmtVisitor) interface{}
+	Span() scanner.Span
 }
 
 type ExprStmt struct {
 	Expression Expr
+	node
 }
 
 func (e *ExprStmt) Accept(v StmtVisitor) interface{} {
@@ -16,6 +18,7 @@ func (e *ExprStmt) Accept(v StmtVisitor) interface{} {
 
 type PrintStmt struct {
 	Expression Expr
+	node
 }
 
 func (p *PrintStmt) Accept(v StmtVisitor) interface{} {
@@ -25,6 +28,7 @@ func (p *PrintStmt) Accept(v StmtVisitor) interface{} {
 type VarStmt struct {
 	Name        *scanner.Token
 	Initializer Expr
+	node
 }
 
 func (v *VarStmt) Accept(v_ StmtVisitor) interface{} {
@@ -33,6 +37,7 @@ func (v *VarStmt) Accept(v_ StmtVisitor) interface{} {
 
 type BlockStmt struct {
 	Statements []Stmt
+	node
 }
 
 func (b *BlockStmt) Accept(v StmtVisitor) interface{} {
@@ -43,6 +48,7 @@ type IfStmt struct {
 	Condition  Expr
 	ThenBranch Stmt
 	ElseBranch Stmt
+	node
 }
 
 func (i *IfStmt) Accept(v StmtVisitor) interface{} {
@@ -52,6 +58,8 @@ func (i *IfStmt) Accept(v StmtVisitor) interface{} {
 type WhileStmt struct {
 	Condition Expr
 	Body      Stmt
+	Increment Expr
+	node
 }
 
 func (w *WhileStmt) Accept(v StmtVisitor) interface{} {
@@ -62,6 +70,7 @@ type FunStmt struct {
 	Name   *scanner.Token
 	Params []*scanner.Token
 	Body   *BlockStmt
+	node
 }
 
 func (f *FunStmt) Accept(v StmtVisitor) interface{} {
@@ -71,6 +80,7 @@ func (f *FunStmt) Accept(v StmtVisitor) interface{} {
 type ReturnStmt struct {
 	Keyword *scanner.Token
 	Value   Expr
+	node
 }
 
 func (r *ReturnStmt) Accept(v StmtVisitor) interface{} {
@@ -81,8 +91,61 @@ type ClassStmt struct {
 	Name    *scanner.Token
 	Super   *VariableExpr
 	Methods []*FunStmt
+	node
 }
 
 func (c *ClassStmt) Accept(v StmtVisitor) interface{} {
 	return v.VisitClassStmt(c)
 }
+
+type BreakStmt struct {
+	Keyword *scanner.Token
+	node
+}
+
+func (b *BreakStmt) Accept(v StmtVisitor) interface{} {
+	return v.VisitBreakStmt(b)
+}
+
+type ContinueStmt struct {
+	Keyword *scanner.Token
+	node
+}
+
+func (c *ContinueStmt) Accept(v StmtVisitor) interface{} {
+	return v.VisitContinueStmt(c)
+}
+
+type ThrowStmt struct {
+	Keyword *scanner.Token
+	Value   Expr
+	node
+}
+
+func (t *ThrowStmt) Accept(v StmtVisitor) interface{} {
+	return v.VisitThrowStmt(t)
+}
+
+type TryStmt struct {
+	Keyword *scanner.Token
+	Body    *BlockStmt
+	Name    *scanner.Token
+	Catch   *BlockStmt
+	Finally *BlockStmt
+	node
+}
+
+func (t *TryStmt) Accept(v StmtVisitor) interface{} {
+	return v.VisitTryStmt(t)
+}
+
+type ImportStmt struct {
+	Keyword *scanner.Token
+	Name    *scanner.Token
+	Path    *scanner.Token
+	node
+}
+
+func (i *ImportStmt) Accept(v StmtVisitor) interface{} {
+	return v.VisitImportStmt(i)
+}
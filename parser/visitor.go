@@ -13,6 +13,12 @@ type ExprVisitor interface {
 	VisitSetExpr(s *SetExpr) interface{}
 	VisitThisExpr(t *ThisExpr) interface{}
 	VisitSuperExpr(s *SuperExpr) interface{}
+	VisitFunctionExpr(f *FunctionExpr) interface{}
+	VisitListExpr(l *ListExpr) interface{}
+	VisitIndexGetExpr(i *IndexGetExpr) interface{}
+	VisitIndexSetExpr(i *IndexSetExpr) interface{}
+	VisitMapExpr(m *MapExpr) interface{}
+	VisitInterpolationExpr(i *InterpolationExpr) interface{}
 }
 
 type StmtVisitor interface {
@@ -25,4 +31,9 @@ type StmtVisitor interface {
 	VisitFunStmt(f *FunStmt) interface{}
 	VisitReturnStmt(r *ReturnStmt) interface{}
 	VisitClassStmt(c *ClassStmt) interface{}
+	VisitBreakStmt(b *BreakStmt) interface{}
+	VisitContinueStmt(c *ContinueStmt) interface{}
+	VisitThrowStmt(t *ThrowStmt) interface{}
+	VisitTryStmt(t *TryStmt) interface{}
+	VisitImportStmt(i *ImportStmt) interface{}
 }
@@ -2,11 +2,22 @@ package resolver
 
 import (
 	"github.com/Shri333/golox/fault"
-	"github.com/Shri333/golox/interpreter"
 	"github.com/Shri333/golox/parser"
 	"github.com/Shri333/golox/scanner"
 )
 
+// diagnostic codes
+const (
+	E_TOP_LEVEL_RETURN = "E201"
+	E_INIT_RETURN      = "E202"
+	E_SELF_INHERIT     = "E203"
+	E_OUTSIDE_LOOP     = "E204"
+	E_OWN_INITIALIZER  = "E205"
+	E_OUTSIDE_CLASS    = "E206"
+	E_NO_SUPERCLASS    = "E207"
+	E_REDECLARED       = "E208"
+)
+
 const (
 	F_NONE     = 0
 	F_FUNCTION = 1
@@ -17,21 +28,29 @@ const (
 	C_SUBCLASS = 2
 )
 
+type variable struct {
+	slot    int
+	defined bool
+}
+
 type Resolver struct {
-	i      *interpreter.Interpreter
-	scopes []map[string]bool
-	ftype  int
-	ctype  int
+	scopes   []map[string]*variable
+	ftype    int
+	ctype    int
+	loops    int
+	file     string
+	reporter fault.Reporter
 }
 
-func NewResolver(i *interpreter.Interpreter) *Resolver {
-	return &Resolver{i, []map[string]bool{}, F_NONE, C_NONE}
+func NewResolver(file string, reporter fault.Reporter) *Resolver {
+	return &Resolver{[]map[string]*variable{}, F_NONE, C_NONE, 0, file, reporter}
 }
 
 func (r *Resolver) Resolve(stmts []parser.Stmt) (err error) {
 	defer func() {
 		if r_ := recover(); r_ != nil {
 			err = r_.(error)
+			r.reporter.Report(err)
 		}
 	}()
 
@@ -63,7 +82,7 @@ func (r *Resolver) VisitVarStmt(v *parser.VarStmt) interface{} {
 }
 
 func (r *Resolver) VisitBlockStmt(b *parser.BlockStmt) interface{} {
-	r.scopes = append(r.scopes, make(map[string]bool))
+	r.scopes = append(r.scopes, make(map[string]*variable))
 	for _, stmt := range b.Statements {
 		stmt.Accept(r)
 	}
@@ -84,25 +103,31 @@ func (r *Resolver) VisitIfStmt(i *parser.IfStmt) interface{} {
 
 func (r *Resolver) VisitWhileStmt(w *parser.WhileStmt) interface{} {
 	w.Condition.Accept(r)
+	r.loops++
 	w.Body.Accept(r)
+	r.loops--
+	if w.Increment != nil {
+		w.Increment.Accept(r)
+	}
+
 	return nil
 }
 
 func (r *Resolver) VisitFunStmt(f *parser.FunStmt) interface{} {
 	r.declare(f.Name)
 	r.define(f.Name)
-	r.resolveFunction(f, F_FUNCTION)
+	r.resolveFunction(f.Params, f.Body, F_FUNCTION)
 	return nil
 }
 
 func (r *Resolver) VisitReturnStmt(r_ *parser.ReturnStmt) interface{} {
 	if r.ftype == F_NONE {
-		panic(fault.NewFault(r_.Keyword.Line, "cannot return outside of a function"))
+		panic(r.diagnose(r_.Keyword, E_TOP_LEVEL_RETURN, "cannot return outside of a function"))
 	}
 
 	if r_.Value != nil {
 		if r.ftype == F_INIT {
-			panic(fault.NewFault(r_.Keyword.Line, "cannot return a value from an initializer"))
+			panic(r.diagnose(r_.Keyword, E_INIT_RETURN, "cannot return a value from an initializer"))
 		}
 
 		r_.Value.Accept(r)
@@ -118,27 +143,23 @@ func (r *Resolver) VisitClassStmt(c *parser.ClassStmt) interface{} {
 	r.define(c.Name)
 	if c.Super != nil {
 		if c.Name.Lexeme == c.Super.Name.Lexeme {
-			panic(fault.NewFault(c.Super.Name.Line, "a class cannot inherit from itself"))
+			panic(r.diagnose(c.Super.Name, E_SELF_INHERIT, "a class cannot inherit from itself"))
 		}
 		r.ctype = C_SUBCLASS
 		c.Super.Accept(r)
 	}
 
 	if c.Super != nil {
-		r.scopes = append(r.scopes, make(map[string]bool))
-		scope := r.scopes[len(r.scopes)-1]
-		scope["super"] = true
+		r.scopes = append(r.scopes, map[string]*variable{"super": {0, true}})
 	}
 
-	r.scopes = append(r.scopes, make(map[string]bool))
-	scope := r.scopes[len(r.scopes)-1]
-	scope["this"] = true
+	r.scopes = append(r.scopes, map[string]*variable{"this": {0, true}})
 
 	for _, method := range c.Methods {
 		if method.Name.Lexeme == "init" {
-			r.resolveFunction(method, F_INIT)
+			r.resolveFunction(method.Params, method.Body, F_INIT)
 		} else {
-			r.resolveFunction(method, F_METHOD)
+			r.resolveFunction(method.Params, method.Body, F_METHOD)
 		}
 	}
 
@@ -151,6 +172,52 @@ func (r *Resolver) VisitClassStmt(c *parser.ClassStmt) interface{} {
 	return nil
 }
 
+func (r *Resolver) VisitBreakStmt(b *parser.BreakStmt) interface{} {
+	if r.loops == 0 {
+		panic(r.diagnose(b.Keyword, E_OUTSIDE_LOOP, "cannot use 'break' outside of a loop"))
+	}
+
+	return nil
+}
+
+func (r *Resolver) VisitContinueStmt(c *parser.ContinueStmt) interface{} {
+	if r.loops == 0 {
+		panic(r.diagnose(c.Keyword, E_OUTSIDE_LOOP, "cannot use 'continue' outside of a loop"))
+	}
+
+	return nil
+}
+
+func (r *Resolver) VisitThrowStmt(t *parser.ThrowStmt) interface{} {
+	t.Value.Accept(r)
+	return nil
+}
+
+func (r *Resolver) VisitTryStmt(t *parser.TryStmt) interface{} {
+	t.Body.Accept(r)
+	if t.Catch != nil {
+		r.scopes = append(r.scopes, make(map[string]*variable))
+		r.declare(t.Name)
+		r.define(t.Name)
+		for _, stmt := range t.Catch.Statements {
+			stmt.Accept(r)
+		}
+		r.scopes = r.scopes[:len(r.scopes)-1]
+	}
+
+	if t.Finally != nil {
+		t.Finally.Accept(r)
+	}
+
+	return nil
+}
+
+func (r *Resolver) VisitImportStmt(i *parser.ImportStmt) interface{} {
+	r.declare(i.Name)
+	r.define(i.Name)
+	return nil
+}
+
 func (r *Resolver) VisitBinaryExpr(b *parser.BinaryExpr) interface{} {
 	b.Left.Accept(r)
 	b.Right.Accept(r)
@@ -174,18 +241,18 @@ func (r *Resolver) VisitUnaryExpr(u *parser.UnaryExpr) interface{} {
 func (r *Resolver) VisitVariableExpr(v *parser.VariableExpr) interface{} {
 	if len(r.scopes) > 0 {
 		scope := r.scopes[len(r.scopes)-1]
-		if value, ok := scope[v.Name.Lexeme]; ok && !value {
-			panic(fault.NewFault(v.Name.Line, "cannot read local variable in its own initializer"))
+		if variable, ok := scope[v.Name.Lexeme]; ok && !variable.defined {
+			panic(r.diagnose(v.Name, E_OWN_INITIALIZER, "cannot read local variable in its own initializer"))
 		}
 	}
 
-	r.resolveLocal(v, v.Name)
+	v.Local = r.resolveLocal(v.Name)
 	return nil
 }
 
 func (r *Resolver) VisitAssignExpr(a *parser.AssignExpr) interface{} {
 	a.Value.Accept(r)
-	r.resolveLocal(a, a.Name)
+	a.Local = r.resolveLocal(a.Name)
 	return nil
 }
 
@@ -217,23 +284,66 @@ func (r *Resolver) VisitSetExpr(s *parser.SetExpr) interface{} {
 
 func (r *Resolver) VisitThisExpr(t *parser.ThisExpr) interface{} {
 	if r.ctype == C_NONE {
-		panic(fault.NewFault(t.Keyword.Line, "cannot use 'this' outside of a class"))
+		panic(r.diagnose(t.Keyword, E_OUTSIDE_CLASS, "cannot use 'this' outside of a class"))
 	}
 
-	r.resolveLocal(t, t.Keyword)
+	t.Local = r.resolveLocal(t.Keyword)
 	return nil
 }
 
 func (r *Resolver) VisitSuperExpr(s *parser.SuperExpr) interface{} {
 	if r.ctype == C_NONE {
-		panic(fault.NewFault(s.Keyword.Line, "cannot use 'super' outside of a class"))
+		panic(r.diagnose(s.Keyword, E_OUTSIDE_CLASS, "cannot use 'super' outside of a class"))
 	}
 
 	if r.ctype == C_CLASS {
-		panic(fault.NewFault(s.Keyword.Line, "cannot use 'super' in a class with no superclass"))
+		panic(r.diagnose(s.Keyword, E_NO_SUPERCLASS, "cannot use 'super' in a class with no superclass"))
+	}
+
+	s.Local = r.resolveLocal(s.Keyword)
+	return nil
+}
+
+func (r *Resolver) VisitFunctionExpr(f *parser.FunctionExpr) interface{} {
+	r.resolveFunction(f.Params, f.Body, F_FUNCTION)
+	return nil
+}
+
+func (r *Resolver) VisitListExpr(l *parser.ListExpr) interface{} {
+	for _, element := range l.Elements {
+		element.Accept(r)
+	}
+
+	return nil
+}
+
+func (r *Resolver) VisitIndexGetExpr(i *parser.IndexGetExpr) interface{} {
+	i.Object.Accept(r)
+	i.Index.Accept(r)
+	return nil
+}
+
+func (r *Resolver) VisitIndexSetExpr(i *parser.IndexSetExpr) interface{} {
+	i.Value.Accept(r)
+	i.Object.Accept(r)
+	i.Index.Accept(r)
+	return nil
+}
+
+func (r *Resolver) VisitMapExpr(m *parser.MapExpr) interface{} {
+	for i := range m.Keys {
+		m.Keys[i].Accept(r)
+		m.Values[i].Accept(r)
+	}
+
+	return nil
+}
+
+func (r *Resolver) VisitInterpolationExpr(i *parser.InterpolationExpr) interface{} {
+	for _, part := range i.Parts {
+		part.Accept(r)
 	}
 
-	r.resolveLocal(s, s.Keyword)
 	return nil
 }
 
@@ -241,42 +351,48 @@ func (r *Resolver) declare(name *scanner.Token) {
 	if len(r.scopes) > 0 {
 		scope := r.scopes[len(r.scopes)-1]
 		if _, ok := scope[name.Lexeme]; ok {
-			panic(fault.NewFault(name.Line, "variable cannot be redeclared in local scope"))
+			panic(r.diagnose(name, E_REDECLARED, "variable cannot be redeclared in local scope"))
 		}
-		scope[name.Lexeme] = false
+		scope[name.Lexeme] = &variable{len(scope), false}
 	}
 }
 
 func (r *Resolver) define(name *scanner.Token) {
 	if len(r.scopes) > 0 {
 		scope := r.scopes[len(r.scopes)-1]
-		scope[name.Lexeme] = true
+		scope[name.Lexeme].defined = true
 	}
 }
 
-func (r *Resolver) resolveLocal(expr parser.Expr, name *scanner.Token) {
+func (r *Resolver) resolveLocal(name *scanner.Token) *parser.Local {
 	for i := len(r.scopes) - 1; i >= 0; i-- {
-		if _, ok := r.scopes[i][name.Lexeme]; ok {
-			r.i.Resolve(expr, len(r.scopes)-i-1)
-			return
+		if variable, ok := r.scopes[i][name.Lexeme]; ok {
+			return &parser.Local{Depth: len(r.scopes) - i - 1, Slot: variable.slot}
 		}
 	}
+
+	return nil
 }
 
-func (r *Resolver) resolveFunction(function *parser.FunStmt, ftype int) {
-	enclosing := r.ftype
-	r.ftype = ftype
-	r.scopes = append(r.scopes, make(map[string]bool))
+func (r *Resolver) resolveFunction(params []*scanner.Token, body *parser.BlockStmt, ftype int) {
+	enclosing, loops := r.ftype, r.loops
+	r.ftype, r.loops = ftype, 0
+	r.scopes = append(r.scopes, make(map[string]*variable))
 
-	for _, param := range function.Params {
+	for _, param := range params {
 		r.declare(param)
 		r.define(param)
 	}
 
-	for _, stmt := range function.Body.Statements {
+	for _, stmt := range body.Statements {
 		stmt.Accept(r)
 	}
 
 	r.scopes = r.scopes[:len(r.scopes)-1]
-	r.ftype = enclosing
+	r.ftype, r.loops = enclosing, loops
+}
+
+func (r *Resolver) diagnose(t *scanner.Token, code string, message string) *fault.Diagnostic {
+	span := t.Span()
+	return fault.NewDiagnostic(code, r.file, span.Line, span.Column, span.EndColumn, message)
 }
@@ -3,48 +3,92 @@ package run
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
 
+	"github.com/Shri333/golox/compiler"
 	"github.com/Shri333/golox/fault"
 	"github.com/Shri333/golox/interpreter"
 	"github.com/Shri333/golox/parser"
 	"github.com/Shri333/golox/resolver"
 	"github.com/Shri333/golox/scanner"
+	"github.com/Shri333/golox/vm"
 )
 
-func RunFile(path string) {
+// Diagnostic formats accepted by RunFile and RunPrompt.
+const (
+	FORMAT_TEXT  = "text"
+	FORMAT_JSON  = "json"
+	FORMAT_SARIF = "sarif"
+)
+
+func RunFile(path string, bytecode bool, search []string, format string) {
+	reporter, flush := newReporter(format)
+	code := runFile(path, bytecode, search, os.Stdout, reporter)
+	flush()
+	if code != 0 {
+		os.Exit(code)
+	}
+}
+
+func runFile(path string, bytecode bool, search []string, stdout io.Writer, reporter fault.Reporter) int {
 	bytes, err := os.ReadFile(path)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	stmts, err := scanAndParse(string(bytes))
-	if err != nil && fault.W == os.Stdout {
-		os.Exit(65)
+	if r, ok := reporter.(*fault.Renderer); ok {
+		r.Source(path, string(bytes))
+	}
+	stmts, err := scanAndParse(string(bytes), path, 1, reporter)
+	if err != nil {
+		return 65
+	}
+
+	if bytecode {
+		return runBytecode(path, stmts, search, stdout, reporter)
 	}
 
 	i := interpreter.NewInterpreter()
-	r := resolver.NewResolver(i)
-	err = r.Resolve(stmts)
-	if err != nil && fault.W == os.Stdout {
-		os.Exit(65)
+	if err := i.SetScript(path); err != nil {
+		log.Fatal(err)
+	}
+	i.SetSearchPath(search)
+	i.SetOutput(stdout, reporter)
+	r := resolver.NewResolver(path, reporter)
+	if err := r.Resolve(stmts); err != nil {
+		return 65
 	}
 
-	err = i.Interpret(stmts)
-	if err != nil && fault.W == os.Stdout {
-		os.Exit(70)
+	if err := i.Interpret(stmts); err != nil {
+		return 70
 	}
+
+	return 0
 }
 
-func RunPrompt() {
+func RunPrompt(search []string, format string) {
 	s := bufio.NewScanner(os.Stdin)
+	reporter, flush := newReporter(format)
 	i := interpreter.NewInterpreter()
+	i.SetSearchPath(search)
+	i.SetOutput(os.Stdout, reporter)
+	// Lines are numbered across the session, so that errors in functions
+	// entered earlier point at the line they were entered on.
+	var session strings.Builder
+	line := 1
 	fmt.Print("> ")
 	for s.Scan() {
-		stmts, err := scanAndParse(s.Text())
+		session.WriteString(s.Text() + "\n")
+		if r, ok := reporter.(*fault.Renderer); ok {
+			r.Source("", session.String())
+		}
+		stmts, err := scanAndParse(s.Text(), "", line, reporter)
+		line++
 		if err == nil {
-			r := resolver.NewResolver(i)
+			r := resolver.NewResolver("", reporter)
 			err = r.Resolve(stmts)
 		}
 		if err == nil {
@@ -53,24 +97,72 @@ func RunPrompt() {
 		fmt.Print("> ")
 	}
 
+	flush()
 	if err := s.Err(); err == nil {
 		fmt.Println("bye")
 		os.Exit(0)
 	}
 }
 
-func scanAndParse(source string) ([]parser.Stmt, error) {
-	s := scanner.NewScanner(source)
-	err := s.ScanTokens()
-	if err != nil {
-		return nil, err
+func runBytecode(path string, stmts []parser.Stmt, search []string, stdout io.Writer, reporter fault.Reporter) int {
+	c := compiler.NewCompiler(path, reporter)
+	r := resolver.NewResolver(path, reporter)
+	if err := r.Resolve(stmts); err != nil {
+		return 65
 	}
 
-	p := parser.NewParser(s.Tokens)
-	stmts, err := p.Parse()
+	fn, err := c.Compile(stmts)
 	if err != nil {
-		return nil, err
+		return 65
+	}
+
+	v := vm.NewVM()
+	if err := v.SetScript(path); err != nil {
+		log.Fatal(err)
+	}
+	v.SetSearchPath(search)
+	v.SetOutput(stdout, reporter)
+
+	if err := v.Interpret(fn); err != nil {
+		return 70
+	}
+
+	return 0
+}
+
+func scanAndParse(source string, file string, line int, reporter fault.Reporter) ([]parser.Stmt, error) {
+	s := scanner.NewScanner(source, file, reporter)
+	s.SetLine(line)
+	scanErrs := s.ScanTokens()
+	p := parser.NewParser(s.Tokens, file, reporter)
+	stmts, errs := p.Parse()
+	if errs = append(scanErrs, errs...); errs != nil {
+		return nil, errs
 	}
 
 	return stmts, nil
 }
+
+// newReporter returns the reporter of the CLI for format, which writes to
+// stderr, and a function to call once every error has been reported. Text
+// is coloured when it goes to a terminal and NO_COLOR is not set.
+func newReporter(format string) (fault.Reporter, func()) {
+	switch format {
+	case FORMAT_JSON:
+		return fault.NewJSONReporter(os.Stderr), func() {}
+	case FORMAT_SARIF:
+		s := fault.NewSARIF(os.Stderr)
+		return s, func() {
+			if err := s.Flush(); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	color := false
+	if info, err := os.Stderr.Stat(); err == nil && os.Getenv("NO_COLOR") == "" {
+		color = info.Mode()&os.ModeCharDevice != 0
+	}
+
+	return fault.NewRenderer(os.Stderr, color), func() {}
+}
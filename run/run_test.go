@@ -0,0 +1,60 @@
+package run
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Shri333/golox/fault"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected output of the testdata programs")
+
+// TestPrograms runs every program in testdata on both backends, which must
+// agree with each other and with the program's .out file.
+func TestPrograms(t *testing.T) {
+	programs, err := filepath.Glob(filepath.Join("testdata", "*.lox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, program := range programs {
+		program := program
+		t.Run(strings.TrimSuffix(filepath.Base(program), ".lox"), func(t *testing.T) {
+			walker := execute(program, false)
+			if vm := execute(program, true); vm != walker {
+				t.Fatalf("backends differ\ntree-walker:\n%s\nvm:\n%s", walker, vm)
+			}
+
+			golden := strings.TrimSuffix(program, ".lox") + ".out"
+			if *update {
+				if err := os.WriteFile(golden, []byte(walker), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if walker != string(want) {
+				t.Errorf("output differs\ngot:\n%s\nwant:\n%s", walker, want)
+			}
+		})
+	}
+}
+
+// execute returns what program prints to stdout and stderr, followed by its
+// exit code.
+func execute(program string, bytecode bool) string {
+	var out bytes.Buffer
+	search := []string{filepath.Join("testdata", "imports", "sp")}
+	code := runFile(program, bytecode, search, &out, fault.NewReporter(&out))
+	fmt.Fprintf(&out, "exit %d\n", code)
+	return out.String()
+}
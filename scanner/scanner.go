@@ -3,25 +3,62 @@ package scanner
 import (
 	"fmt"
 	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/Shri333/golox/fault"
 )
 
+// diagnostic codes
+const (
+	E_UNKNOWN_CHARACTER   = "E001"
+	E_UNTERMINATED_STRING = "E002"
+	E_INVALID_UTF8        = "E003"
+	E_INVALID_ESCAPE      = "E004"
+	E_UNTERMINATED_BLOCK  = "E005"
+)
+
 type Scanner struct {
-	Source  string
-	Tokens  []Token
-	start   int
-	current int
-	line    int
-	err     error
+	Source    string
+	Tokens    []Token
+	start     int
+	current   int
+	line      int
+	lineStart int
+	file      string
+	errs      fault.Diagnostics
+	reporter  fault.Reporter
+	// strings whose interpolated expressions are being scanned, innermost
+	// last
+	interpolations []interpolation
 }
 
-func NewScanner(source string) *Scanner {
+// interpolation is a string with an expression being interpolated into it,
+// recorded as the position of its opening quote and the number of braces
+// opened inside the expression so far.
+type interpolation struct {
+	offset int
+	line   int
+	column int
+	braces int
+}
+
+// NewScanner returns a scanner for source read from file, which is only
+// used to locate diagnostics.
+func NewScanner(source string, file string, reporter fault.Reporter) *Scanner {
 	tokens := make([]Token, 0, 10)
-	return &Scanner{source, tokens, 0, 0, 1, nil}
+	return &Scanner{source, tokens, 0, 0, 1, 0, file, nil, reporter, nil}
+}
+
+// SetLine sets the number of the first line of the source, for source that
+// continues earlier source such as a line entered in the REPL.
+func (s *Scanner) SetLine(line int) {
+	s.line = line
 }
 
-func (s *Scanner) ScanTokens() error {
+// ScanTokens fills Tokens and returns every error in the source.
+func (s *Scanner) ScanTokens() fault.Diagnostics {
 	for s.current < len(s.Source) {
 		s.start = s.current
 		switch s.Source[s.current] {
@@ -30,9 +67,26 @@ func (s *Scanner) ScanTokens() error {
 		case ')':
 			s.addToken(RIGHT_PAREN, nil)
 		case '{':
+			if n := len(s.interpolations); n > 0 {
+				s.interpolations[n-1].braces++
+			}
 			s.addToken(LEFT_BRACE, nil)
 		case '}':
+			n := len(s.interpolations)
+			if n > 0 && s.interpolations[n-1].braces == 0 {
+				quote := s.interpolations[n-1]
+				s.interpolations = s.interpolations[:n-1]
+				s.string(quote)
+				break
+			}
+			if n > 0 {
+				s.interpolations[n-1].braces--
+			}
 			s.addToken(RIGHT_BRACE, nil)
+		case '[':
+			s.addToken(LEFT_BRACKET, nil)
+		case ']':
+			s.addToken(RIGHT_BRACKET, nil)
 		case ',':
 			s.addToken(COMMA, nil)
 		case '.':
@@ -43,6 +97,8 @@ func (s *Scanner) ScanTokens() error {
 			s.addToken(PLUS, nil)
 		case ';':
 			s.addToken(SEMICOLON, nil)
+		case ':':
+			s.addToken(COLON, nil)
 		case '*':
 			s.addToken(STAR, nil)
 		case '!':
@@ -72,6 +128,8 @@ func (s *Scanner) ScanTokens() error {
 		case '/':
 			if s.next('/') {
 				s.singleComment()
+			} else if s.next('*') {
+				s.blockComment()
 			} else {
 				s.addToken(SLASH, nil)
 			}
@@ -79,26 +137,39 @@ func (s *Scanner) ScanTokens() error {
 		case '\t':
 		case '\r':
 		case '\n':
-			s.line++
+			s.newline()
 		case '"':
-			err := s.string()
-			if s.err == nil {
-				s.err = err
-			}
+			s.string(interpolation{s.start, s.line, s.column(s.start), 0})
 		default:
+			r, size := utf8.DecodeRuneInString(s.Source[s.current:])
 			if isDigit(s.Source[s.current]) {
 				s.number()
-			} else if isAlpha(s.Source[s.current]) {
+			} else if isAlpha(r) {
 				s.identifier()
+			} else if r == utf8.RuneError && size == 1 {
+				message := fmt.Sprintf("invalid UTF-8 byte 0x%02x", s.Source[s.current])
+				column := s.column(s.current)
+				s.errs = append(s.errs, fault.NewDiagnostic(E_INVALID_UTF8, s.file, s.line, column, column+1, message))
 			} else {
-				message := fmt.Sprintf("unknown character '%c'", s.Source[s.current])
-				s.err = fault.NewFault(s.line, message)
+				message := fmt.Sprintf("unknown character '%c'", r)
+				column := s.column(s.current)
+				s.errs = append(s.errs, fault.NewDiagnostic(E_UNKNOWN_CHARACTER, s.file, s.line, column, column+1, message))
+				s.current += size - 1
 			}
 		}
 		s.current++
 	}
-	s.Tokens = append(s.Tokens, Token{EOF, "EOF", nil, s.line})
-	return s.err
+	for _, quote := range s.interpolations {
+		s.unterminated(quote)
+	}
+	end := len(s.Source)
+	s.Tokens = append(s.Tokens, Token{EOF, "EOF", nil, s.line, s.column(end), end, end})
+	if s.errs != nil {
+		s.reporter.Report(s.errs)
+		return s.errs
+	}
+
+	return nil
 }
 
 func (s *Scanner) singleComment() {
@@ -108,22 +179,165 @@ func (s *Scanner) singleComment() {
 	s.current--
 }
 
-func (s *Scanner) string() error {
+// blockComment skips a /* */ comment, which may contain others, and is
+// reported at its opening if it is never closed.
+func (s *Scanner) blockComment() {
+	line, column := s.line, s.column(s.start)
+	depth := 1
+	s.current++
+	for s.current < len(s.Source) {
+		switch {
+		case strings.HasPrefix(s.Source[s.current:], "/*"):
+			depth++
+			s.current += 2
+		case strings.HasPrefix(s.Source[s.current:], "*/"):
+			depth--
+			s.current += 2
+			if depth == 0 {
+				s.current--
+				return
+			}
+		default:
+			if s.Source[s.current] == '\n' {
+				s.newline()
+			}
+			s.current++
+		}
+	}
+
+	s.errs = append(s.errs, fault.NewDiagnostic(E_UNTERMINATED_BLOCK, s.file, line, column, column+2, "unterminated block comment"))
+	s.current--
+}
+
+func (s *Scanner) newline() {
+	s.line++
+	s.lineStart = s.current + 1
+}
+
+// column returns the column of the byte at offset on the current line.
+func (s *Scanner) column(offset int) int {
+	return utf8.RuneCountInString(s.Source[s.lineStart:offset]) + 1
+}
+
+// string scans a string literal from its opening quote, or what follows an
+// interpolated expression from the brace closing it. The literal is located
+// where the scan started even if it spans several lines, and ends with an
+// INTERPOLATION token if another expression is interpolated into it.
+func (s *Scanner) string(quote interpolation) {
+	line, column := s.line, s.column(s.start)
+	var value strings.Builder
 	s.current++
 	for s.current < len(s.Source) && s.Source[s.current] != '"' {
-		if s.Source[s.current] == '\n' {
-			s.line++
+		switch s.Source[s.current] {
+		case '\\':
+			s.escape(&value)
+			continue
+		case '\n':
+			s.newline()
+		case '$':
+			if s.current+1 < len(s.Source) && s.Source[s.current+1] == '{' {
+				lexeme := s.Source[s.start : s.current+2]
+				s.Tokens = append(s.Tokens, Token{INTERPOLATION, lexeme, value.String(), line, column, s.start, s.current + 2})
+				s.interpolations = append(s.interpolations, quote)
+				s.current++
+				return
+			}
 		}
+		value.WriteByte(s.Source[s.current])
 		s.current++
 	}
 
 	if s.current == len(s.Source) {
-		return fault.NewFault(s.line, "unterminated string")
-	} else {
-		s.addToken(STRING, s.Source[s.start+1:s.current])
+		s.unterminated(quote)
+		return
 	}
 
-	return nil
+	lexeme := s.Source[s.start : s.current+1]
+	s.Tokens = append(s.Tokens, Token{STRING, lexeme, value.String(), line, column, s.start, s.current + 1})
+}
+
+// unterminated reports a string with no closing quote, from its opening
+// quote to the end of that line.
+func (s *Scanner) unterminated(quote interpolation) {
+	rest := s.Source[quote.offset:]
+	if n := strings.IndexByte(rest, '\n'); n >= 0 {
+		rest = rest[:n]
+	}
+	end := quote.column + utf8.RuneCountInString(rest)
+	s.errs = append(s.errs, fault.NewDiagnostic(E_UNTERMINATED_STRING, s.file, quote.line, quote.column, end, "unterminated string"))
+}
+
+// escapes maps the character after a backslash to the one it stands for.
+var escapes = map[byte]byte{'n': '\n', 't': '\t', 'r': '\r', '\\': '\\', '"': '"', '$': '$', '0': 0}
+
+// escape decodes the escape sequence starting at the backslash under
+// current into value and moves past it. \xNN and \u{N...} stand for the
+// character with that hexadecimal code point.
+func (s *Scanner) escape(value *strings.Builder) {
+	start := s.current
+	s.current++
+	if s.current == len(s.Source) {
+		return
+	}
+
+	switch c := s.Source[s.current]; c {
+	case 'n', 't', 'r', '\\', '"', '$', '0':
+		value.WriteByte(escapes[c])
+		s.current++
+		return
+	case 'x':
+		s.current++
+		if digits := s.hex(2); len(digits) == 2 {
+			n, _ := strconv.ParseUint(digits, 16, 8)
+			value.WriteRune(rune(n))
+			return
+		}
+		s.invalidEscape(start, "'\\x' must be followed by two hexadecimal digits")
+		return
+	case 'u':
+		s.current++
+		if s.current == len(s.Source) || s.Source[s.current] != '{' {
+			s.invalidEscape(start, "'\\u' must be followed by a code point in braces")
+			return
+		}
+		s.current++
+		digits := s.hex(6)
+		if s.current == len(s.Source) || s.Source[s.current] != '}' || digits == "" {
+			s.invalidEscape(start, "'\\u{' must be followed by one to six hexadecimal digits and '}'")
+			return
+		}
+		s.current++
+		n, _ := strconv.ParseUint(digits, 16, 32)
+		if n > unicode.MaxRune || n >= 0xd800 && n <= 0xdfff {
+			s.invalidEscape(start, fmt.Sprintf("'%s' is not a valid code point", s.Source[start:s.current]))
+			return
+		}
+		value.WriteRune(rune(n))
+		return
+	case '\n':
+		s.invalidEscape(start, "unknown escape sequence '\\'")
+		return
+	}
+
+	_, size := utf8.DecodeRuneInString(s.Source[s.current:])
+	s.current += size
+	s.invalidEscape(start, fmt.Sprintf("unknown escape sequence '%s'", s.Source[start:s.current]))
+}
+
+// hex moves past up to max hexadecimal digits and returns them.
+func (s *Scanner) hex(max int) string {
+	start := s.current
+	for s.current < len(s.Source) && s.current-start < max && isHex(s.Source[s.current]) {
+		s.current++
+	}
+
+	return s.Source[start:s.current]
+}
+
+// invalidEscape reports the escape sequence from start up to current.
+func (s *Scanner) invalidEscape(start int, message string) {
+	column := s.column(start)
+	s.errs = append(s.errs, fault.NewDiagnostic(E_INVALID_ESCAPE, s.file, s.line, column, s.column(s.current), message))
 }
 
 func (s *Scanner) number() {
@@ -145,9 +359,15 @@ func (s *Scanner) number() {
 	}
 }
 
+// identifier scans a name made of letters, digits and underscores from any
+// script, as well as the combining marks some letters are written with.
 func (s *Scanner) identifier() {
-	for s.current < len(s.Source) && (isAlpha(s.Source[s.current]) || isDigit(s.Source[s.current])) {
-		s.current++
+	for s.current < len(s.Source) {
+		r, size := utf8.DecodeRuneInString(s.Source[s.current:])
+		if !isAlphaNumeric(r) {
+			break
+		}
+		s.current += size
 	}
 
 	s.current--
@@ -161,7 +381,7 @@ func (s *Scanner) identifier() {
 
 func (s *Scanner) addToken(tokenType int, literal interface{}) {
 	lexeme := s.Source[s.start : s.current+1]
-	token := Token{tokenType, lexeme, literal, s.line}
+	token := Token{tokenType, lexeme, literal, s.line, s.column(s.start), s.start, s.current + 1}
 	s.Tokens = append(s.Tokens, token)
 }
 
@@ -178,6 +398,30 @@ func isDigit(c byte) bool {
 	return c >= '0' && c <= '9'
 }
 
-func isAlpha(c byte) bool {
-	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+func isHex(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func isAlpha(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_' || r >= utf8.RuneSelf && unicode.IsLetter(r)
+}
+
+func isAlphaNumeric(r rune) bool {
+	return isAlpha(r) || unicode.IsDigit(r) || unicode.In(r, unicode.Mn, unicode.Mc)
+}
+
+// IsIdentifier reports whether name is scanned as a single identifier, which
+// keywords are not.
+func IsIdentifier(name string) bool {
+	if _, ok := keywords[name]; ok {
+		return false
+	}
+
+	for i, r := range name {
+		if i == 0 && !isAlpha(r) || !isAlphaNumeric(r) {
+			return false
+		}
+	}
+
+	return name != ""
 }
@@ -0,0 +1,331 @@
+package scanner_test
+
+import (
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Shri333/golox/fault"
+	"github.com/Shri333/golox/scanner"
+)
+
+// diagnostic is the part of a fault.Diagnostic that locates it.
+type diagnostic struct {
+	code      string
+	line      int
+	column    int
+	endColumn int
+}
+
+func scan(source string) ([]scanner.Token, []diagnostic) {
+	s := scanner.NewScanner(source, "test.lox", fault.NewReporter(io.Discard))
+	return s.Tokens, locate(s.ScanTokens())
+}
+
+func locate(errs fault.Diagnostics) []diagnostic {
+	var located []diagnostic
+	for _, d := range errs {
+		located = append(located, diagnostic{d.Code, d.Line, d.Column, d.EndColumn})
+	}
+
+	return located
+}
+
+func TestScanDiagnostics(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []diagnostic
+	}{
+		{"print 1;", nil},
+		{"@", []diagnostic{{scanner.E_UNKNOWN_CHARACTER, 1, 1, 2}}},
+		{"a # b\n  $", []diagnostic{{scanner.E_UNKNOWN_CHARACTER, 1, 3, 4}, {scanner.E_UNKNOWN_CHARACTER, 2, 3, 4}}},
+		{`print "open`, []diagnostic{{scanner.E_UNTERMINATED_STRING, 1, 7, 12}}},
+		{"@ \"open\nnext", []diagnostic{{scanner.E_UNKNOWN_CHARACTER, 1, 1, 2}, {scanner.E_UNTERMINATED_STRING, 1, 3, 8}}},
+	}
+
+	for _, test := range tests {
+		if _, got := scan(test.source); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("scan(%q) diagnostics = %v, want %v", test.source, got, test.want)
+		}
+	}
+}
+
+func TestScanDiagnosticFields(t *testing.T) {
+	s := scanner.NewScanner("#", "test.lox", fault.NewReporter(io.Discard))
+	errs := s.ScanTokens()
+	want := fault.NewDiagnostic(scanner.E_UNKNOWN_CHARACTER, "test.lox", 1, 1, 2, "unknown character '#'")
+	if len(errs) != 1 || *errs[0] != *want || errs[0].Severity != fault.SEV_ERROR {
+		t.Errorf("ScanTokens = %v, want %v", errs, want)
+	}
+}
+
+func TestTokenSpans(t *testing.T) {
+	source := "var x = 10;\n  print \"a\nb\" >= x;"
+	tokens, errs := scan(source)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+
+	want := []struct {
+		lexeme string
+		line   int
+		column int
+		offset int
+	}{
+		{"var", 1, 1, 0},
+		{"x", 1, 5, 4},
+		{"=", 1, 7, 6},
+		{"10", 1, 9, 8},
+		{";", 1, 11, 10},
+		{"print", 2, 3, 14},
+		{"\"a\nb\"", 2, 9, 20},
+		{">=", 3, 4, 26},
+		{"x", 3, 7, 29},
+		{";", 3, 8, 30},
+		{"EOF", 3, 9, 31},
+	}
+	if len(tokens) != len(want) {
+		t.Fatalf("scanned %d tokens, want %d", len(tokens), len(want))
+	}
+	for i, w := range want {
+		tok := tokens[i]
+		if tok.Lexeme != w.lexeme || tok.Line != w.line || tok.Column != w.column || tok.Offset != w.offset {
+			t.Errorf("token %d = %q at %d:%d offset %d, want %q at %d:%d offset %d",
+				i, tok.Lexeme, tok.Line, tok.Column, tok.Offset, w.lexeme, w.line, w.column, w.offset)
+		}
+		if w.lexeme != "EOF" && source[tok.Offset:tok.End] != tok.Lexeme {
+			t.Errorf("token %d covers %q, want %q", i, source[tok.Offset:tok.End], tok.Lexeme)
+		}
+	}
+
+	str := tokens[6].Span()
+	if want := (scanner.Span{20, 25, 2, 9, 3, 3}); str != want {
+		t.Errorf("span of %q = %v, want %v", tokens[6].Lexeme, str, want)
+	}
+	if got, want := tokens[5].Span().To(tokens[9].Span()), (scanner.Span{14, 31, 2, 3, 3, 9}); got != want {
+		t.Errorf("span of the statement = %v, want %v", got, want)
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	tokens, errs := scan("var café = \"naïve ☃\"; ☃ 日本 e\u0301x")
+	want := []diagnostic{{scanner.E_UNKNOWN_CHARACTER, 1, 23, 24}}
+	if !reflect.DeepEqual(errs, want) {
+		t.Errorf("diagnostics = %v, want %v", errs, want)
+	}
+
+	lexemes := []string{"var", "café", "=", "\"naïve ☃\"", ";", "日本", "e\u0301x", "EOF"}
+	columns := []int{1, 5, 10, 12, 21, 25, 28, 31}
+	for i, tok := range tokens {
+		if i >= len(lexemes) || tok.Lexeme != lexemes[i] || tok.Column != columns[i] {
+			t.Errorf("token %d = %q at column %d", i, tok.Lexeme, tok.Column)
+		}
+	}
+	if tokens[3].Literal != "naïve ☃" {
+		t.Errorf("string literal = %q", tokens[3].Literal)
+	}
+
+	s := scanner.NewScanner("a ☃", "", fault.NewReporter(io.Discard))
+	if errs := s.ScanTokens(); len(errs) != 1 || errs[0].Message != "unknown character '☃'" {
+		t.Errorf("ScanTokens = %v", errs)
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []diagnostic
+	}{
+		{"a\xffb", []diagnostic{{scanner.E_INVALID_UTF8, 1, 2, 3}}},
+		{"é\xc3", []diagnostic{{scanner.E_INVALID_UTF8, 1, 2, 3}}},
+		{"\xe2\x98 x\n\xff", []diagnostic{{scanner.E_INVALID_UTF8, 1, 1, 2}, {scanner.E_INVALID_UTF8, 1, 2, 3}, {scanner.E_INVALID_UTF8, 2, 1, 2}}},
+	}
+
+	for _, test := range tests {
+		if _, got := scan(test.source); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("scan(%q) diagnostics = %v, want %v", test.source, got, test.want)
+		}
+	}
+}
+
+func TestIsIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"util", true},
+		{"_private2", true},
+		{"café", true},
+		{"日本", true},
+		{"e\u0301", true},
+		{"", false},
+		{"2d", false},
+		{"my-lib", false},
+		{"a b", false},
+		{"\u0301e", false},
+		{"class", false},
+		{"nil", false},
+		{"import", false},
+	}
+
+	for _, test := range tests {
+		if got := scanner.IsIdentifier(test.name); got != test.want {
+			t.Errorf("IsIdentifier(%q) = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestEscapes(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`"a\nb"`, "a\nb"},
+		{`"\t\r\0"`, "\t\r\x00"},
+		{`"\"q\" \\ \$"`, `"q" \ $`},
+		{`"\x41\x7e\xe9"`, "A~é"},
+		{`"\u{e9}\u{1F600}\u{10FFFF}"`, "é😀\U0010FFFF"},
+		{`"\u{000041}"`, "A"},
+	}
+
+	for _, test := range tests {
+		tokens, errs := scan(test.source)
+		if errs != nil {
+			t.Errorf("scan(%s) diagnostics = %v", test.source, errs)
+			continue
+		}
+		if tokens[0].Literal != test.want {
+			t.Errorf("scan(%s) literal = %q, want %q", test.source, tokens[0].Literal, test.want)
+		}
+	}
+}
+
+func TestInvalidEscapes(t *testing.T) {
+	tests := []struct {
+		source  string
+		column  int
+		end     int
+		message string
+	}{
+		{`"\q"`, 2, 4, `unknown escape sequence '\q'`},
+		{`"ab \é"`, 5, 7, `unknown escape sequence '\é'`},
+		{`"\x4g"`, 2, 5, `'\x' must be followed by two hexadecimal digits`},
+		{`"\x"`, 2, 4, `'\x' must be followed by two hexadecimal digits`},
+		{`"\u41"`, 2, 4, `'\u' must be followed by a code point in braces`},
+		{`"\u{}"`, 2, 5, `'\u{' must be followed by one to six hexadecimal digits and '}'`},
+		{`"\u{1234567}"`, 2, 11, `'\u{' must be followed by one to six hexadecimal digits and '}'`},
+		{`"\u{D800}"`, 2, 10, `'\u{D800}' is not a valid code point`},
+		{`"\u{dfff}"`, 2, 10, `'\u{dfff}' is not a valid code point`},
+		{`"\u{110000}"`, 2, 12, `'\u{110000}' is not a valid code point`},
+		{"\"a\\\nb\"", 3, 4, `unknown escape sequence '\'`},
+	}
+
+	for _, test := range tests {
+		s := scanner.NewScanner(test.source, "", fault.NewReporter(io.Discard))
+		errs := s.ScanTokens()
+		if len(errs) != 1 {
+			t.Errorf("scan(%s) diagnostics = %v, want one", test.source, errs)
+			continue
+		}
+		d := errs[0]
+		if d.Code != scanner.E_INVALID_ESCAPE || d.Line != 1 || d.Column != test.column || d.EndColumn != test.end || d.Message != test.message {
+			t.Errorf("scan(%s) = %s %d:%d-%d %q, want %s 1:%d-%d %q", test.source, d.Code, d.Line, d.Column, d.EndColumn,
+				d.Message, scanner.E_INVALID_ESCAPE, test.column, test.end, test.message)
+		}
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{`"a ${b} c"`, []string{`INTERPOLATION "\"a ${" "a "`, `IDENTIFIER "b"`, `STRING "} c\"" " c"`}},
+		{`"${a}${b}"`, []string{`INTERPOLATION "\"${" ""`, `IDENTIFIER "a"`, `INTERPOLATION "}${" ""`, `IDENTIFIER "b"`, `STRING "}\"" ""`}},
+		{`"x ${ {"k": 1}["k"] } y"`, []string{`INTERPOLATION "\"x ${" "x "`, `LEFT_BRACE "{"`, `STRING "\"k\"" "k"`,
+			`COLON ":"`, `NUMBER "1"`, `RIGHT_BRACE "}"`, `LEFT_BRACKET "["`, `STRING "\"k\"" "k"`, `RIGHT_BRACKET "]"`, `STRING "} y\"" " y"`}},
+		{`"a ${"b ${c}"} d"`, []string{`INTERPOLATION "\"a ${" "a "`, `INTERPOLATION "\"b ${" "b "`, `IDENTIFIER "c"`,
+			`STRING "}\"" ""`, `STRING "} d\"" " d"`}},
+		{`"\${x}"`, []string{`STRING "\"\\${x}\"" "${x}"`}},
+	}
+
+	for _, test := range tests {
+		tokens, errs := scan(test.source)
+		if errs != nil {
+			t.Errorf("scan(%s) diagnostics = %v", test.source, errs)
+			continue
+		}
+		if got := describe(tokens[:len(tokens)-1]); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("scan(%s) =\n%s\nwant\n%s", test.source, strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+		}
+	}
+
+	tokens, _ := scan("\"a ${\nb} c\"")
+	if tok := tokens[2]; tok.Line != 2 || tok.Column != 2 {
+		t.Errorf("string after interpolation at %d:%d, want 2:2", tok.Line, tok.Column)
+	}
+	if _, errs := scan(`"a ${b`); !reflect.DeepEqual(errs, []diagnostic{{scanner.E_UNTERMINATED_STRING, 1, 1, 7}}) {
+		t.Errorf("unterminated interpolation diagnostics = %v", errs)
+	}
+}
+
+var tokenNames = map[int]string{
+	scanner.INTERPOLATION: "INTERPOLATION",
+	scanner.STRING:        "STRING",
+	scanner.IDENTIFIER:    "IDENTIFIER",
+	scanner.NUMBER:        "NUMBER",
+	scanner.LEFT_BRACE:    "LEFT_BRACE",
+	scanner.RIGHT_BRACE:   "RIGHT_BRACE",
+	scanner.LEFT_BRACKET:  "LEFT_BRACKET",
+	scanner.RIGHT_BRACKET: "RIGHT_BRACKET",
+	scanner.COLON:         "COLON",
+}
+
+// describe returns each token as its type and lexeme, followed by the
+// literal of strings.
+func describe(tokens []scanner.Token) []string {
+	described := make([]string, len(tokens))
+	for i, tok := range tokens {
+		described[i] = tokenNames[tok.TokenType] + " " + strconv.Quote(tok.Lexeme)
+		if s, ok := tok.Literal.(string); ok {
+			described[i] += " " + strconv.Quote(s)
+		}
+	}
+
+	return described
+}
+
+func TestBlockComments(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+		errs   []diagnostic
+	}{
+		{"/* a */ 1", []string{"1"}, nil},
+		{"/* a /* b /* c */ */ still */ 1", []string{"1"}, nil},
+		{"1 /**/ 2 /* é */ 3", []string{"1", "2", "3"}, nil},
+		{"/*/ 1 */ 2", []string{"2"}, nil},
+		{"1 // /* not a block\n2", []string{"1", "2"}, nil},
+		{"1 /* a */ */ 2", []string{"1", "*", "/", "2"}, nil},
+		{"1 /* open", []string{"1"}, []diagnostic{{scanner.E_UNTERMINATED_BLOCK, 1, 3, 5}}},
+		{"1\n  /* a /* b */ c", []string{"1"}, []diagnostic{{scanner.E_UNTERMINATED_BLOCK, 2, 3, 5}}},
+	}
+
+	for _, test := range tests {
+		tokens, errs := scan(test.source)
+		var lexemes []string
+		for _, tok := range tokens[:len(tokens)-1] {
+			lexemes = append(lexemes, tok.Lexeme)
+		}
+		if !reflect.DeepEqual(lexemes, test.want) || !reflect.DeepEqual(errs, test.errs) {
+			t.Errorf("scan(%q) = %q %v, want %q %v", test.source, lexemes, errs, test.want, test.errs)
+		}
+	}
+
+	tokens, _ := scan("/* a\n/* b\n*/\n*/ x")
+	if tok := tokens[0]; tok.Line != 4 || tok.Column != 4 {
+		t.Errorf("token after comment at %d:%d, want 4:4", tok.Line, tok.Column)
+	}
+}
@@ -1,77 +1,139 @@
 package scanner
 
+import (
+	"strings"
+	"unicode/utf8"
+)
+
 const (
 	// single-character tokens
-	LEFT_PAREN  = -1
-	RIGHT_PAREN = -2
-	LEFT_BRACE  = -3
-	RIGHT_BRACE = -4
-	COMMA       = -5
-	DOT         = -6
-	MINUS       = -7
-	PLUS        = -8
-	SEMICOLON   = -9
-	SLASH       = -10
-	STAR        = -11
+	LEFT_PAREN    = -1
+	RIGHT_PAREN   = -2
+	LEFT_BRACE    = -3
+	RIGHT_BRACE   = -4
+	COMMA         = -5
+	DOT           = -6
+	MINUS         = -7
+	PLUS          = -8
+	SEMICOLON     = -9
+	SLASH         = -10
+	STAR          = -11
+	LEFT_BRACKET  = -12
+	RIGHT_BRACKET = -13
+	COLON         = -14
 
 	// one or two-character tokens
-	BANG          = -12
-	BANG_EQUAL    = -13
-	EQUAL         = -14
-	EQUAL_EQUAL   = -15
-	GREATER       = -16
-	GREATER_EQUAL = -17
-	LESS          = -18
-	LESS_EQUAL    = -19
+	BANG          = -15
+	BANG_EQUAL    = -16
+	EQUAL         = -17
+	EQUAL_EQUAL   = -18
+	GREATER       = -19
+	GREATER_EQUAL = -20
+	LESS          = -21
+	LESS_EQUAL    = -22
 
 	// literals
-	IDENTIFIER = -20
-	STRING     = -21
-	NUMBER     = -22
+	IDENTIFIER = -23
+	STRING     = -24
+	NUMBER     = -25
+
+	// the part of a string before an interpolated expression, up to "${"
+	INTERPOLATION = -26
 
 	// keywords
-	AND    = -23
-	CLASS  = -24
-	ELSE   = -25
-	FALSE  = -26
-	FUN    = -27
-	FOR    = -28
-	IF     = -29
-	NIL    = -30
-	OR     = -31
-	PRINT  = -32
-	RETURN = -33
-	SUPER  = -34
-	THIS   = -35
-	TRUE   = -36
-	VAR    = -37
-	WHILE  = -38
+	AND      = -27
+	CLASS    = -28
+	ELSE     = -29
+	FALSE    = -30
+	FUN      = -31
+	FOR      = -32
+	IF       = -33
+	NIL      = -34
+	OR       = -35
+	PRINT    = -36
+	RETURN   = -37
+	SUPER    = -38
+	THIS     = -39
+	TRUE     = -40
+	VAR      = -41
+	WHILE    = -42
+	BREAK    = -43
+	CONTINUE = -44
+	THROW    = -45
+	TRY      = -46
+	CATCH    = -47
+	FINALLY  = -48
+	IMPORT   = -49
 
-	EOF = -39
+	EOF = -50
 )
 
 var keywords = map[string]int{
-	"and":    AND,
-	"class":  CLASS,
-	"else":   ELSE,
-	"false":  FALSE,
-	"for":    FOR,
-	"fun":    FUN,
-	"if":     IF,
-	"nil":    NIL,
-	"or":     OR,
-	"print":  PRINT,
-	"return": RETURN,
-	"super":  SUPER,
-	"this":   THIS,
-	"true":   TRUE,
-	"var":    VAR,
-	"while":  WHILE,
+	"and":      AND,
+	"class":    CLASS,
+	"else":     ELSE,
+	"false":    FALSE,
+	"for":      FOR,
+	"fun":      FUN,
+	"if":       IF,
+	"nil":      NIL,
+	"or":       OR,
+	"print":    PRINT,
+	"return":   RETURN,
+	"super":    SUPER,
+	"this":     THIS,
+	"true":     TRUE,
+	"var":      VAR,
+	"while":    WHILE,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"throw":    THROW,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"import":   IMPORT,
 }
 
+// Token is a lexeme of the source, found at bytes Offset up to End. Its
+// Column counts UTF-8 characters from 1.
 type Token struct {
 	TokenType int
 	Lexeme    string
 	Literal   interface{}
 	Line      int
+	Column    int
+	Offset    int
+	End       int
+}
+
+func (t Token) Span() Span {
+	span := Span{t.Offset, t.End, t.Line, t.Column, t.Line, t.Column}
+	if t.End == t.Offset {
+		return span
+	}
+
+	if n := strings.LastIndexByte(t.Lexeme, '\n'); n >= 0 {
+		span.EndLine += strings.Count(t.Lexeme, "\n")
+		span.EndColumn = utf8.RuneCountInString(t.Lexeme[n+1:]) + 1
+	} else {
+		span.EndColumn += utf8.RuneCountInString(t.Lexeme)
+	}
+
+	return span
+}
+
+// Span is a range of source text, from the byte at Offset up to the byte at
+// End. The end position is just past the last character.
+type Span struct {
+	Offset    int
+	End       int
+	Line      int
+	Column    int
+	EndLine   int
+	EndColumn int
+}
+
+// To returns the span from the start of s to the end of end.
+func (s Span) To(end Span) Span {
+	return Span{s.Offset, end.End, s.Line, s.Column, end.EndLine, end.EndColumn}
 }
@@ -0,0 +1,122 @@
+package value
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type List struct {
+	Elements []interface{}
+}
+
+func NewList(elements []interface{}) *List {
+	return &List{elements}
+}
+
+func (l *List) Get(index interface{}) (interface{}, error) {
+	n, err := listIndex(index, len(l.Elements))
+	if err != nil {
+		return nil, err
+	}
+
+	return l.Elements[n], nil
+}
+
+func (l *List) Set(index interface{}, value interface{}) error {
+	n, err := listIndex(index, len(l.Elements))
+	if err != nil {
+		return err
+	}
+
+	l.Elements[n] = value
+	return nil
+}
+
+// Method returns the method name of l, or nil if there is none.
+func (l *List) Method(name string) *Method {
+	switch name {
+	case "len":
+		return &Method{"len", 0, func(args []interface{}) (interface{}, error) {
+			return float64(len(l.Elements)), nil
+		}}
+	case "push":
+		return &Method{"push", 1, func(args []interface{}) (interface{}, error) {
+			l.Elements = append(l.Elements, args[0])
+			return nil, nil
+		}}
+	case "pop":
+		return &Method{"pop", 0, func(args []interface{}) (interface{}, error) {
+			if len(l.Elements) == 0 {
+				return nil, errors.New("cannot pop from an empty list")
+			}
+			value := l.Elements[len(l.Elements)-1]
+			l.Elements = l.Elements[:len(l.Elements)-1]
+			return value, nil
+		}}
+	case "insert":
+		return &Method{"insert", 2, func(args []interface{}) (interface{}, error) {
+			index, err := listIndex(args[0], len(l.Elements)+1)
+			if err != nil {
+				return nil, err
+			}
+			l.Elements = append(l.Elements, nil)
+			copy(l.Elements[index+1:], l.Elements[index:])
+			l.Elements[index] = args[1]
+			return nil, nil
+		}}
+	case "slice":
+		return &Method{"slice", 2, func(args []interface{}) (interface{}, error) {
+			start, err := listIndex(args[0], len(l.Elements)+1)
+			if err != nil {
+				return nil, err
+			}
+			end, err := listIndex(args[1], len(l.Elements)+1)
+			if err != nil {
+				return nil, err
+			}
+			if start > end {
+				return nil, errors.New("slice start cannot be greater than end")
+			}
+			elements := make([]interface{}, end-start)
+			copy(elements, l.Elements[start:end])
+			return &List{elements}, nil
+		}}
+	}
+
+	return nil
+}
+
+func (l *List) String() string {
+	return l.format(make(map[interface{}]bool))
+}
+
+func (l *List) format(seen map[interface{}]bool) string {
+	if seen[l] {
+		return "[...]"
+	}
+	seen[l] = true
+	defer delete(seen, l)
+
+	elements := make([]string, len(l.Elements))
+	for i, element := range l.Elements {
+		elements[i] = repr(element, seen)
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+func listIndex(value interface{}, size int) (int, error) {
+	n, ok := value.(float64)
+	if !ok || n != math.Trunc(n) {
+		return 0, errors.New("list index must be an integer")
+	}
+
+	if n < 0 || n >= float64(size) {
+		return 0, fmt.Errorf("list index %s out of range", strconv.FormatFloat(n, 'f', -1, 64))
+	}
+
+	return int(n), nil
+}
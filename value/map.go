@@ -0,0 +1,137 @@
+package value
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Map keeps its entries in insertion order. Keys and Values are only read
+// from outside the package; Set and remove keep them in step with index.
+type Map struct {
+	index  map[interface{}]int
+	Keys   []interface{}
+	Values []interface{}
+}
+
+func NewMap() *Map {
+	return &Map{make(map[interface{}]int), nil, nil}
+}
+
+func (m *Map) Get(key interface{}) (interface{}, error) {
+	if err := CheckKey(key); err != nil {
+		return nil, err
+	}
+
+	if i, ok := m.index[key]; ok {
+		return m.Values[i], nil
+	}
+
+	return nil, fmt.Errorf("key %s not found in map", repr(key, make(map[interface{}]bool)))
+}
+
+func (m *Map) Set(key interface{}, value interface{}) error {
+	if err := CheckKey(key); err != nil {
+		return err
+	}
+
+	if i, ok := m.index[key]; ok {
+		m.Values[i] = value
+		return nil
+	}
+
+	m.index[key] = len(m.Keys)
+	m.Keys = append(m.Keys, key)
+	m.Values = append(m.Values, value)
+	return nil
+}
+
+// Method returns the method name of m, or nil if there is none.
+func (m *Map) Method(name string) *Method {
+	switch name {
+	case "len":
+		return &Method{"len", 0, func(args []interface{}) (interface{}, error) {
+			return float64(len(m.Keys)), nil
+		}}
+	case "keys":
+		return &Method{"keys", 0, func(args []interface{}) (interface{}, error) {
+			keys := make([]interface{}, len(m.Keys))
+			copy(keys, m.Keys)
+			return &List{keys}, nil
+		}}
+	case "values":
+		return &Method{"values", 0, func(args []interface{}) (interface{}, error) {
+			values := make([]interface{}, len(m.Values))
+			copy(values, m.Values)
+			return &List{values}, nil
+		}}
+	case "has":
+		return &Method{"has", 1, func(args []interface{}) (interface{}, error) {
+			if err := CheckKey(args[0]); err != nil {
+				return nil, err
+			}
+			_, ok := m.index[args[0]]
+			return ok, nil
+		}}
+	case "remove":
+		return &Method{"remove", 1, func(args []interface{}) (interface{}, error) {
+			if err := CheckKey(args[0]); err != nil {
+				return nil, err
+			}
+			return m.remove(args[0]), nil
+		}}
+	}
+
+	return nil
+}
+
+func (m *Map) remove(key interface{}) interface{} {
+	i, ok := m.index[key]
+	if !ok {
+		return nil
+	}
+
+	value := m.Values[i]
+	delete(m.index, key)
+	m.Keys = append(m.Keys[:i], m.Keys[i+1:]...)
+	m.Values = append(m.Values[:i], m.Values[i+1:]...)
+	for j := i; j < len(m.Keys); j++ {
+		m.index[m.Keys[j]] = j
+	}
+
+	return value
+}
+
+func (m *Map) String() string {
+	return m.format(make(map[interface{}]bool))
+}
+
+func (m *Map) format(seen map[interface{}]bool) string {
+	if seen[m] {
+		return "{...}"
+	}
+	seen[m] = true
+	defer delete(seen, m)
+
+	entries := make([]string, len(m.Keys))
+	for i, key := range m.Keys {
+		entries[i] = repr(key, seen) + ": " + repr(m.Values[i], seen)
+	}
+
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+// CheckKey returns an error if key cannot be used as a map key.
+func CheckKey(key interface{}) error {
+	switch k := key.(type) {
+	case nil, bool, string, Instance:
+		return nil
+	case float64:
+		if k != k {
+			return errors.New("map key cannot be NaN")
+		}
+		return nil
+	}
+
+	return errors.New("map keys must be strings, numbers, booleans, nil or instances")
+}
@@ -0,0 +1,58 @@
+// Package value holds the Lox values that behave the same on both backends:
+// lists, maps and the way values are printed.
+package value
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Instance is implemented by instances of Lox classes, which can be used as
+// map keys and are compared by identity.
+type Instance interface {
+	ClassName() string
+}
+
+// Collection is a value that can be indexed with brackets and has methods.
+type Collection interface {
+	Get(index interface{}) (interface{}, error)
+	Set(index interface{}, value interface{}) error
+	Method(name string) *Method
+}
+
+// Method is a method of a list or map, bound to it. It is called with
+// exactly Arity arguments.
+type Method struct {
+	Name  string
+	Arity int
+	Fn    func(args []interface{}) (interface{}, error)
+}
+
+// Stringify returns value as print shows it.
+func Stringify(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	}
+
+	return fmt.Sprint(value)
+}
+
+// repr returns value as it is shown inside a list or map, where strings are
+// quoted and lists and maps already being shown are elided.
+func repr(value interface{}, seen map[interface{}]bool) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case *List:
+		return v.format(seen)
+	case *Map:
+		return v.format(seen)
+	}
+
+	return Stringify(value)
+}
@@ -0,0 +1,141 @@
+package value
+
+import (
+	"math"
+	"testing"
+)
+
+type testInstance struct{}
+
+func (*testInstance) ClassName() string { return "Test" }
+
+func TestListIndex(t *testing.T) {
+	l := NewList([]interface{}{"a", "b"})
+	tests := []struct {
+		index interface{}
+		want  string
+	}{
+		{1.0, ""},
+		{1.5, "list index must be an integer"},
+		{"0", "list index must be an integer"},
+		{-1.0, "list index -1 out of range"},
+		{2.0, "list index 2 out of range"},
+	}
+
+	for _, test := range tests {
+		_, err := l.Get(test.index)
+		if got := errorString(err); got != test.want {
+			t.Errorf("Get(%v) error = %q, want %q", test.index, got, test.want)
+		}
+		err = l.Set(test.index, nil)
+		if got := errorString(err); got != test.want {
+			t.Errorf("Set(%v) error = %q, want %q", test.index, got, test.want)
+		}
+	}
+}
+
+func TestListMethods(t *testing.T) {
+	l := NewList(nil)
+	call(t, l, "push", 1.0)
+	call(t, l, "push", 3.0)
+	call(t, l, "insert", 1.0, 2.0)
+	if got := Stringify(l); got != "[1, 2, 3]" {
+		t.Errorf("list = %s", got)
+	}
+	if got := Stringify(call(t, l, "slice", 1.0, 3.0)); got != "[2, 3]" {
+		t.Errorf("slice = %s", got)
+	}
+	if got := call(t, l, "pop"); got != 3.0 {
+		t.Errorf("pop = %v", got)
+	}
+
+	if _, err := l.Method("slice").Fn([]interface{}{2.0, 1.0}); errorString(err) != "slice start cannot be greater than end" {
+		t.Errorf("reversed slice error = %v", err)
+	}
+	if _, err := NewList(nil).Method("pop").Fn(nil); errorString(err) != "cannot pop from an empty list" {
+		t.Errorf("empty pop error = %v", err)
+	}
+	if l.Method("sort") != nil {
+		t.Error("found an undefined method")
+	}
+}
+
+func TestCheckKey(t *testing.T) {
+	tests := []struct {
+		key  interface{}
+		want string
+	}{
+		{nil, ""},
+		{true, ""},
+		{"k", ""},
+		{1.5, ""},
+		{&testInstance{}, ""},
+		{math.NaN(), "map key cannot be NaN"},
+		{NewList(nil), "map keys must be strings, numbers, booleans, nil or instances"},
+		{NewMap(), "map keys must be strings, numbers, booleans, nil or instances"},
+	}
+
+	for _, test := range tests {
+		if got := errorString(CheckKey(test.key)); got != test.want {
+			t.Errorf("CheckKey(%v) = %q, want %q", test.key, got, test.want)
+		}
+	}
+}
+
+func TestMap(t *testing.T) {
+	m := NewMap()
+	for _, key := range []interface{}{"b", 1.0, nil, "a"} {
+		if err := m.Set(key, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.Set("b", 2.0)
+	if got := Stringify(m); got != `{"b": 2, 1: 1, <nil>: <nil>, "a": "a"}` {
+		t.Errorf("map = %s", got)
+	}
+
+	if got := call(t, m, "remove", 1.0); got != 1.0 {
+		t.Errorf("remove = %v", got)
+	}
+	if got := call(t, m, "has", 1.0); got != false {
+		t.Errorf("has after remove = %v", got)
+	}
+	if got := Stringify(call(t, m, "keys")); got != `["b", <nil>, "a"]` {
+		t.Errorf("keys = %s", got)
+	}
+	if v, err := m.Get("a"); err != nil || v != "a" {
+		t.Errorf("Get(a) = %v, %v", v, err)
+	}
+	if _, err := m.Get("z"); errorString(err) != `key "z" not found in map` {
+		t.Errorf("Get(z) error = %v", err)
+	}
+}
+
+func TestStringifyCycles(t *testing.T) {
+	l := NewList(nil)
+	m := NewMap()
+	l.Elements = append(l.Elements, l, m)
+	m.Set("self", m)
+	m.Set("list", l)
+	if got := Stringify(l); got != `[[...], {"self": {...}, "list": [...]}]` {
+		t.Errorf("list = %s", got)
+	}
+}
+
+func call(t *testing.T, c Collection, name string, args ...interface{}) interface{} {
+	t.Helper()
+	result, err := c.Method(name).Fn(args)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+
+	return result
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
@@ -0,0 +1,96 @@
+package vm
+
+import (
+	"github.com/Shri333/golox/fault"
+	"github.com/Shri333/golox/value"
+)
+
+// exception is a value thrown by a throw statement or a runtime error on
+// its way to the innermost handler.
+type exception struct {
+	value  interface{}
+	line   int
+	column int
+	end    int
+	file   string
+	trace  []fault.Frame
+}
+
+func (e *exception) Error() string {
+	return e.fault().Error()
+}
+
+func (e *exception) fault() *fault.Fault {
+	if l, ok := e.value.(*loxError); ok {
+		return l.fault
+	}
+
+	f := fault.NewAt(e.line, e.column, e.end, "uncaught exception: "+value.Stringify(e.value))
+	f.SetTrace(e.trace)
+	f.SetFile(e.file)
+	return f
+}
+
+// record takes a stack trace the first time an exception is caught, while
+// the frames it was raised in are still on the stack.
+func (vm *VM) record(e *exception) {
+	if e.trace != nil {
+		return
+	}
+
+	l, ok := e.value.(*loxError)
+	if ok && l.fault.Trace() != nil {
+		e.trace, e.file = l.fault.Trace(), l.fault.File()
+		return
+	}
+
+	e.trace = vm.traceback()
+	e.file = vm.frames[len(vm.frames)-1].closure.module.path
+	if ok {
+		l.fault.SetTrace(e.trace)
+		l.fault.SetFile(e.file)
+	}
+}
+
+func (vm *VM) traceback() []fault.Frame {
+	trace := make([]fault.Frame, 0, len(vm.frames))
+	for j := len(vm.frames) - 1; j >= 0; j-- {
+		f := &vm.frames[j]
+		name := f.closure.function.Name
+		if f.closure == f.closure.module.script {
+			name = f.closure.module.name
+			if f.closure.module == vm.main {
+				name = "script"
+			}
+		}
+		trace = append(trace, fault.Frame{Function: name, Line: f.closure.function.Chunk.Lines[f.ip-1]})
+	}
+
+	return trace
+}
+
+type handler struct {
+	frame int
+	top   int
+	ip    int
+	raw   bool
+}
+
+type loxError struct {
+	fault *fault.Fault
+}
+
+func (e *loxError) get(name string) (interface{}, bool) {
+	switch name {
+	case "message":
+		return e.fault.Message(), true
+	case "line":
+		return float64(e.fault.Line()), true
+	}
+
+	return nil, false
+}
+
+func (e loxError) String() string {
+	return e.fault.Error()
+}
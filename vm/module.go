@@ -0,0 +1,60 @@
+package vm
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Shri333/golox/compiler"
+	"github.com/Shri333/golox/loader"
+)
+
+// module is the namespace produced by an import. Closures created while
+// running its script keep a pointer to it and read its globals.
+type module struct {
+	name    string
+	path    string
+	globals map[string]interface{}
+	script  *closure
+}
+
+func newModule(path string) *module {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return &module{name, path, make(map[string]interface{}), nil}
+}
+
+func (m module) String() string {
+	return fmt.Sprintf("<module %s>", m.name)
+}
+
+// load returns the module an import from the given module refers to, and
+// whether it is new and its script still has to run.
+func (vm *VM) load(path string, from *module) (*module, bool, error) {
+	file, err := loader.Find(path, filepath.Dir(from.path), vm.search)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var fn *compiler.Function
+	if m, ok := vm.modules[file]; ok {
+		for _, f := range vm.frames {
+			if f.closure == m.script {
+				return nil, false, fmt.Errorf("import cycle: '%s' is already being imported", path)
+			}
+		}
+		return m, false, nil
+	}
+
+	stmts, err := loader.Load(file, vm.reporter)
+	if err == nil {
+		fn, err = compiler.NewCompiler(file, vm.reporter).Compile(stmts)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("could not import '%s'", path)
+	}
+
+	m := newModule(file)
+	m.script = &closure{fn, nil, m}
+	vm.modules[file] = m
+	return m, true, nil
+}
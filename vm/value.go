@@ -0,0 +1,69 @@
+package vm
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Shri333/golox/compiler"
+)
+
+type native struct {
+	name  string
+	arity int
+	fn    func(args []interface{}) (interface{}, error)
+}
+
+func (n native) String() string {
+	return fmt.Sprintf("<native function %s>", n.name)
+}
+
+func clock(args []interface{}) (interface{}, error) {
+	return float64(time.Now().UnixMilli() / 1000), nil
+}
+
+type upvalue struct {
+	slot   int
+	closed interface{}
+	next   *upvalue
+}
+
+type closure struct {
+	function *compiler.Function
+	upvalues []*upvalue
+	module   *module
+}
+
+func (c closure) String() string {
+	return c.function.String()
+}
+
+type class struct {
+	name    string
+	methods map[string]*closure
+}
+
+func (c class) String() string {
+	return fmt.Sprintf("<class %s>", c.name)
+}
+
+type instance struct {
+	c      *class
+	fields map[string]interface{}
+}
+
+func (i instance) String() string {
+	return fmt.Sprintf("%s instance", i.c.name)
+}
+
+type boundMethod struct {
+	receiver interface{}
+	method   *closure
+}
+
+func (b boundMethod) String() string {
+	return b.method.String()
+}
+
+func (i *instance) ClassName() string {
+	return i.c.name
+}
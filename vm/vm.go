@@ -0,0 +1,591 @@
+package vm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Shri333/golox/compiler"
+	"github.com/Shri333/golox/fault"
+	"github.com/Shri333/golox/parser"
+	"github.com/Shri333/golox/value"
+)
+
+// FRAMES_MAX is the default limit on nested calls; see parser.MAX_DEPTH.
+const FRAMES_MAX = parser.MAX_DEPTH
+
+type frame struct {
+	closure  *closure
+	ip       int
+	base     int
+	returned interface{}
+}
+
+type VM struct {
+	stack    []interface{}
+	top      int
+	frames   []frame
+	builtins map[string]interface{}
+	open     *upvalue
+	handlers []handler
+	main     *module
+	modules  map[string]*module
+	search   []string
+	depth    int
+	stdout   io.Writer
+	reporter fault.Reporter
+}
+
+func NewVM() *VM {
+	builtins := make(map[string]interface{})
+	builtins["clock"] = &native{"clock", 0, clock}
+	return &VM{make([]interface{}, 256), 0, make([]frame, 0, 64), builtins, nil, nil, newModule(""), make(map[string]*module), nil, FRAMES_MAX, os.Stdout, fault.NewReporter(os.Stderr)}
+}
+
+// SetOutput directs print statements to stdout and uncaught errors to
+// reporter. They default to os.Stdout and a reporter printing to os.Stderr.
+func (vm *VM) SetOutput(stdout io.Writer, reporter fault.Reporter) {
+	vm.stdout = stdout
+	vm.reporter = reporter
+}
+
+// SetScript records the file the compiled script comes from, so that its
+// imports are found relative to it and importing it back is a cycle.
+func (vm *VM) SetScript(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	delete(vm.modules, vm.main.path)
+	vm.main.name = newModule(abs).name
+	vm.main.path = abs
+	vm.modules[abs] = vm.main
+	return nil
+}
+
+// SetSearchPath sets the directories searched for imports that are not
+// found next to the importing file.
+func (vm *VM) SetSearchPath(dirs []string) {
+	vm.search = dirs
+}
+
+// SetMaxDepth limits how deeply calls and imports may nest before a stack
+// overflow error is raised.
+func (vm *VM) SetMaxDepth(depth int) {
+	vm.depth = depth
+}
+
+func (vm *VM) Interpret(fn *compiler.Function) error {
+	script := &closure{fn, nil, vm.main}
+	vm.main.script = script
+	vm.push(script)
+	vm.frames = append(vm.frames, frame{script, 0, 0, nil})
+
+	for {
+		err := vm.run()
+		if err == nil {
+			return nil
+		}
+
+		e := err.(*exception)
+		vm.record(e)
+		if len(vm.handlers) == 0 {
+			vm.unwind(0)
+			vm.top = 0
+			vm.open = nil
+			f := e.fault()
+			vm.reporter.Report(f)
+			return f
+		}
+
+		vm.catch(e)
+	}
+}
+
+func (vm *VM) run() error {
+	f := &vm.frames[len(vm.frames)-1]
+	code := f.closure.function.Chunk.Code
+	constants := f.closure.function.Chunk.Constants
+	globals := f.closure.module.globals
+	for {
+		op := code[f.ip]
+		f.ip++
+		switch op {
+		case compiler.OP_CONSTANT:
+			index := int(code[f.ip])<<8 | int(code[f.ip+1])
+			f.ip += 2
+			vm.push(constants[index])
+		case compiler.OP_NIL:
+			vm.push(nil)
+		case compiler.OP_TRUE:
+			vm.push(true)
+		case compiler.OP_FALSE:
+			vm.push(false)
+		case compiler.OP_POP:
+			vm.top--
+		case compiler.OP_GET_LOCAL:
+			vm.push(vm.stack[f.base+int(code[f.ip])])
+			f.ip++
+		case compiler.OP_SET_LOCAL:
+			vm.stack[f.base+int(code[f.ip])] = vm.stack[vm.top-1]
+			f.ip++
+		case compiler.OP_GET_GLOBAL:
+			name := constants[int(code[f.ip])<<8|int(code[f.ip+1])].(string)
+			f.ip += 2
+			value, ok := globals[name]
+			if !ok {
+				if value, ok = vm.builtins[name]; !ok {
+					return vm.runtimeError(fmt.Sprintf("undefined variable '%s'", name))
+				}
+			}
+			vm.push(value)
+		case compiler.OP_DEFINE_GLOBAL:
+			name := constants[int(code[f.ip])<<8|int(code[f.ip+1])].(string)
+			f.ip += 2
+			vm.top--
+			globals[name] = vm.stack[vm.top]
+		case compiler.OP_SET_GLOBAL:
+			name := constants[int(code[f.ip])<<8|int(code[f.ip+1])].(string)
+			f.ip += 2
+			if _, ok := globals[name]; !ok {
+				if _, ok := vm.builtins[name]; !ok {
+					return vm.runtimeError(fmt.Sprintf("undefined variable '%s'", name))
+				}
+			}
+			globals[name] = vm.stack[vm.top-1]
+		case compiler.OP_GET_UPVALUE:
+			u := f.closure.upvalues[code[f.ip]]
+			f.ip++
+			if u.slot >= 0 {
+				vm.push(vm.stack[u.slot])
+			} else {
+				vm.push(u.closed)
+			}
+		case compiler.OP_SET_UPVALUE:
+			u := f.closure.upvalues[code[f.ip]]
+			f.ip++
+			if u.slot >= 0 {
+				vm.stack[u.slot] = vm.stack[vm.top-1]
+			} else {
+				u.closed = vm.stack[vm.top-1]
+			}
+		case compiler.OP_GET_PROPERTY:
+			name := constants[int(code[f.ip])<<8|int(code[f.ip+1])].(string)
+			f.ip += 2
+			inst, ok := vm.stack[vm.top-1].(*instance)
+			if !ok {
+				var method *native
+				switch object := vm.stack[vm.top-1].(type) {
+				case value.Collection:
+					if m := object.Method(name); m != nil {
+						method = &native{m.Name, m.Arity, m.Fn}
+					}
+				case *module:
+					value, ok := object.globals[name]
+					if !ok {
+						return vm.runtimeError(fmt.Sprintf("undefined property %s", name))
+					}
+					vm.stack[vm.top-1] = value
+					continue
+				case *loxError:
+					value, ok := object.get(name)
+					if !ok {
+						return vm.runtimeError(fmt.Sprintf("undefined property %s", name))
+					}
+					vm.stack[vm.top-1] = value
+					continue
+				default:
+					return vm.runtimeError("only instances have properties")
+				}
+				if method == nil {
+					return vm.runtimeError(fmt.Sprintf("undefined property %s", name))
+				}
+				vm.stack[vm.top-1] = method
+				continue
+			}
+			if value, ok := inst.fields[name]; ok {
+				vm.stack[vm.top-1] = value
+			} else if method, ok := inst.c.methods[name]; ok {
+				vm.stack[vm.top-1] = &boundMethod{inst, method}
+			} else {
+				return vm.runtimeError(fmt.Sprintf("undefined property %s", name))
+			}
+		case compiler.OP_SET_PROPERTY:
+			name := constants[int(code[f.ip])<<8|int(code[f.ip+1])].(string)
+			f.ip += 2
+			inst, ok := vm.stack[vm.top-2].(*instance)
+			if !ok {
+				return vm.runtimeError("only instances have fields")
+			}
+			value := vm.stack[vm.top-1]
+			inst.fields[name] = value
+			vm.top--
+			vm.stack[vm.top-1] = value
+		case compiler.OP_GET_SUPER:
+			name := constants[int(code[f.ip])<<8|int(code[f.ip+1])].(string)
+			f.ip += 2
+			vm.top--
+			super := vm.stack[vm.top].(*class)
+			method, ok := super.methods[name]
+			if !ok {
+				return vm.runtimeError(fmt.Sprintf("undefined property '%s'", name))
+			}
+			vm.stack[vm.top-1] = &boundMethod{vm.stack[vm.top-1], method}
+		case compiler.OP_EQUAL:
+			vm.top--
+			vm.stack[vm.top-1] = vm.stack[vm.top-1] == vm.stack[vm.top]
+		case compiler.OP_NOT_EQUAL:
+			vm.top--
+			vm.stack[vm.top-1] = vm.stack[vm.top-1] != vm.stack[vm.top]
+		case compiler.OP_GREATER, compiler.OP_GREATER_EQUAL, compiler.OP_LESS, compiler.OP_LESS_EQUAL,
+			compiler.OP_SUBTRACT, compiler.OP_MULTIPLY, compiler.OP_DIVIDE:
+			left, leftOk := vm.stack[vm.top-2].(float64)
+			right, rightOk := vm.stack[vm.top-1].(float64)
+			if !leftOk || !rightOk {
+				return vm.runtimeError("operands must be numbers")
+			}
+			vm.top--
+			vm.stack[vm.top-1] = arithmetic(op, left, right)
+		case compiler.OP_ADD:
+			switch left := vm.stack[vm.top-2].(type) {
+			case float64:
+				if right, ok := vm.stack[vm.top-1].(float64); ok {
+					vm.top--
+					vm.stack[vm.top-1] = left + right
+					continue
+				}
+			case string:
+				if right, ok := vm.stack[vm.top-1].(string); ok {
+					vm.top--
+					vm.stack[vm.top-1] = left + right
+					continue
+				}
+			}
+			return vm.runtimeError("operands must be two numbers or two strings")
+		case compiler.OP_NOT:
+			vm.stack[vm.top-1] = !isTruthy(vm.stack[vm.top-1])
+		case compiler.OP_NEGATE:
+			value, ok := vm.stack[vm.top-1].(float64)
+			if !ok {
+				return vm.runtimeError("operand must be a number")
+			}
+			vm.stack[vm.top-1] = -value
+		case compiler.OP_PRINT:
+			vm.top--
+			fmt.Fprintln(vm.stdout, value.Stringify(vm.stack[vm.top]))
+		case compiler.OP_JUMP:
+			f.ip += int(code[f.ip])<<8 | int(code[f.ip+1]) + 2
+		case compiler.OP_JUMP_IF_FALSE:
+			if isTruthy(vm.stack[vm.top-1]) {
+				f.ip += 2
+			} else {
+				f.ip += int(code[f.ip])<<8 | int(code[f.ip+1]) + 2
+			}
+		case compiler.OP_LOOP:
+			f.ip -= int(code[f.ip])<<8 | int(code[f.ip+1]) - 2
+		case compiler.OP_CALL:
+			argc := int(code[f.ip])
+			f.ip++
+			if err := vm.callValue(vm.stack[vm.top-argc-1], argc); err != nil {
+				return err
+			}
+			f = &vm.frames[len(vm.frames)-1]
+			code = f.closure.function.Chunk.Code
+			constants = f.closure.function.Chunk.Constants
+			globals = f.closure.module.globals
+		case compiler.OP_CLOSURE:
+			fn := constants[int(code[f.ip])<<8|int(code[f.ip+1])].(*compiler.Function)
+			f.ip += 2
+			c := &closure{fn, make([]*upvalue, fn.Upvalues), f.closure.module}
+			for i := range c.upvalues {
+				if code[f.ip] == 1 {
+					c.upvalues[i] = vm.capture(f.base + int(code[f.ip+1]))
+				} else {
+					c.upvalues[i] = f.closure.upvalues[code[f.ip+1]]
+				}
+				f.ip += 2
+			}
+			vm.push(c)
+		case compiler.OP_CLOSE_UPVALUE:
+			vm.close(vm.top - 1)
+			vm.top--
+		case compiler.OP_RETURN:
+			result := vm.stack[vm.top-1]
+			vm.close(f.base)
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			if len(vm.frames) == 0 {
+				vm.top = 0
+				return nil
+			}
+			vm.top = f.base
+			vm.push(result)
+			f = &vm.frames[len(vm.frames)-1]
+			code = f.closure.function.Chunk.Code
+			constants = f.closure.function.Chunk.Constants
+			globals = f.closure.module.globals
+		case compiler.OP_CLASS:
+			name := constants[int(code[f.ip])<<8|int(code[f.ip+1])].(string)
+			f.ip += 2
+			vm.push(&class{name, make(map[string]*closure)})
+		case compiler.OP_INHERIT:
+			name := constants[int(code[f.ip])<<8|int(code[f.ip+1])].(string)
+			f.ip += 2
+			super, ok := vm.stack[vm.top-2].(*class)
+			if !ok {
+				return vm.runtimeError(fmt.Sprintf("%s is a not a class", name))
+			}
+			sub := vm.stack[vm.top-1].(*class)
+			for key, method := range super.methods {
+				sub.methods[key] = method
+			}
+			vm.top--
+		case compiler.OP_METHOD:
+			name := constants[int(code[f.ip])<<8|int(code[f.ip+1])].(string)
+			f.ip += 2
+			vm.stack[vm.top-2].(*class).methods[name] = vm.stack[vm.top-1].(*closure)
+			vm.top--
+		case compiler.OP_LIST:
+			count := int(code[f.ip])<<8 | int(code[f.ip+1])
+			f.ip += 2
+			elements := make([]interface{}, count)
+			copy(elements, vm.stack[vm.top-count:vm.top])
+			vm.top -= count
+			vm.push(value.NewList(elements))
+		case compiler.OP_GET_INDEX:
+			c, ok := vm.stack[vm.top-2].(value.Collection)
+			if !ok {
+				return vm.runtimeError("only lists and maps can be indexed")
+			}
+			element, err := c.Get(vm.stack[vm.top-1])
+			if err != nil {
+				return vm.runtimeError(err.Error())
+			}
+			vm.top--
+			vm.stack[vm.top-1] = element
+		case compiler.OP_SET_INDEX:
+			c, ok := vm.stack[vm.top-3].(value.Collection)
+			if !ok {
+				return vm.runtimeError("only lists and maps can be indexed")
+			}
+			element := vm.stack[vm.top-1]
+			if err := c.Set(vm.stack[vm.top-2], element); err != nil {
+				return vm.runtimeError(err.Error())
+			}
+			vm.top -= 2
+			vm.stack[vm.top-1] = element
+		case compiler.OP_MAP:
+			count := int(code[f.ip])<<8 | int(code[f.ip+1])
+			f.ip += 2
+			d := value.NewMap()
+			for i := vm.top - 2*count; i < vm.top; i += 2 {
+				if err := d.Set(vm.stack[i], vm.stack[i+1]); err != nil {
+					return vm.runtimeError(err.Error())
+				}
+			}
+			vm.top -= 2 * count
+			vm.push(d)
+		case compiler.OP_INTERPOLATE:
+			count := int(code[f.ip])<<8 | int(code[f.ip+1])
+			f.ip += 2
+			var b strings.Builder
+			for _, part := range vm.stack[vm.top-count : vm.top] {
+				b.WriteString(value.Stringify(part))
+			}
+			vm.top -= count
+			vm.push(b.String())
+		case compiler.OP_THROW:
+			vm.top--
+			if e, ok := vm.stack[vm.top].(*exception); ok {
+				return e
+			}
+			chunk := &f.closure.function.Chunk
+			columns := chunk.Columns[f.ip-1]
+			return &exception{vm.stack[vm.top], chunk.Lines[f.ip-1], columns[0], columns[1], "", nil}
+		case compiler.OP_TRY:
+			ip := f.ip + 2 + (int(code[f.ip])<<8 | int(code[f.ip+1]))
+			vm.handlers = append(vm.handlers, handler{len(vm.frames) - 1, vm.top, ip, code[f.ip+2] == 1})
+			f.ip += 3
+		case compiler.OP_END_TRY:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case compiler.OP_STASH:
+			vm.top--
+			f.returned = vm.stack[vm.top]
+		case compiler.OP_UNSTASH:
+			vm.push(f.returned)
+		case compiler.OP_IMPORT:
+			path := constants[int(code[f.ip])<<8|int(code[f.ip+1])].(string)
+			f.ip += 2
+			if len(vm.frames) >= vm.depth {
+				return vm.runtimeError("stack overflow")
+			}
+			m, fresh, err := vm.load(path, f.closure.module)
+			if err != nil {
+				return vm.runtimeError(err.Error())
+			}
+			vm.push(m)
+			if !fresh {
+				continue
+			}
+			vm.frames = append(vm.frames, frame{m.script, 0, vm.top - 1, nil})
+			f = &vm.frames[len(vm.frames)-1]
+			code = f.closure.function.Chunk.Code
+			constants = f.closure.function.Chunk.Constants
+			globals = f.closure.module.globals
+		}
+	}
+}
+
+func (vm *VM) callValue(callee interface{}, argc int) error {
+	switch c := callee.(type) {
+	case *closure:
+		return vm.call(c, argc)
+	case *boundMethod:
+		vm.stack[vm.top-argc-1] = c.receiver
+		return vm.call(c.method, argc)
+	case *class:
+		vm.stack[vm.top-argc-1] = &instance{c, make(map[string]interface{})}
+		if initializer, ok := c.methods["init"]; ok {
+			return vm.call(initializer, argc)
+		}
+		if argc != 0 {
+			return vm.runtimeError(fmt.Sprintf("expected 0 arguments but got %d", argc))
+		}
+		return nil
+	case *native:
+		if argc != c.arity {
+			return vm.runtimeError(fmt.Sprintf("expected %d arguments but got %d", c.arity, argc))
+		}
+		result, err := c.fn(vm.stack[vm.top-argc : vm.top])
+		if err != nil {
+			return vm.runtimeError(err.Error())
+		}
+		vm.top -= argc + 1
+		vm.push(result)
+		return nil
+	}
+
+	return vm.runtimeError("can only call functions and classes")
+}
+
+func (vm *VM) call(c *closure, argc int) error {
+	if argc != c.function.Arity {
+		return vm.runtimeError(fmt.Sprintf("expected %d arguments but got %d", c.function.Arity, argc))
+	}
+
+	if len(vm.frames) >= vm.depth {
+		return vm.runtimeError("stack overflow")
+	}
+
+	vm.frames = append(vm.frames, frame{c, 0, vm.top - argc - 1, nil})
+	return nil
+}
+
+// catch unwinds to the innermost handler and resumes there with the thrown
+// value on the stack, or the exception itself for a handler that rethrows.
+func (vm *VM) catch(e *exception) {
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	vm.close(h.top)
+	vm.unwind(h.frame + 1)
+	vm.frames[h.frame].ip = h.ip
+	vm.top = h.top
+	if h.raw {
+		vm.push(e)
+	} else {
+		vm.push(e.value)
+	}
+}
+
+// unwind drops the frames above the first n, forgetting any module whose
+// script was abandoned so that importing it again starts over.
+func (vm *VM) unwind(n int) {
+	for _, f := range vm.frames[n:] {
+		if m := f.closure.module; f.closure == m.script && m != vm.main {
+			delete(vm.modules, m.path)
+		}
+	}
+
+	vm.frames = vm.frames[:n]
+}
+
+func (vm *VM) capture(slot int) *upvalue {
+	var prev *upvalue
+	u := vm.open
+	for u != nil && u.slot > slot {
+		prev = u
+		u = u.next
+	}
+
+	if u != nil && u.slot == slot {
+		return u
+	}
+
+	created := &upvalue{slot, nil, u}
+	if prev == nil {
+		vm.open = created
+	} else {
+		prev.next = created
+	}
+
+	return created
+}
+
+func (vm *VM) close(last int) {
+	for vm.open != nil && vm.open.slot >= last {
+		u := vm.open
+		u.closed = vm.stack[u.slot]
+		u.slot = -1
+		vm.open = u.next
+	}
+}
+
+func (vm *VM) push(value interface{}) {
+	if vm.top == len(vm.stack) {
+		vm.stack = append(vm.stack, nil)
+		vm.stack = vm.stack[:cap(vm.stack)]
+	}
+
+	vm.stack[vm.top] = value
+	vm.top++
+}
+
+func (vm *VM) runtimeError(message string) error {
+	f := &vm.frames[len(vm.frames)-1]
+	chunk := &f.closure.function.Chunk
+	line, columns := chunk.Lines[f.ip-1], chunk.Columns[f.ip-1]
+	return &exception{&loxError{fault.NewAt(line, columns[0], columns[1], message)}, line, columns[0], columns[1], "", nil}
+}
+
+func arithmetic(op byte, left float64, right float64) interface{} {
+	switch op {
+	case compiler.OP_GREATER:
+		return left > right
+	case compiler.OP_GREATER_EQUAL:
+		return left >= right
+	case compiler.OP_LESS:
+		return left < right
+	case compiler.OP_LESS_EQUAL:
+		return left <= right
+	case compiler.OP_SUBTRACT:
+		return left - right
+	case compiler.OP_MULTIPLY:
+		return left * right
+	}
+
+	return left / right
+}
+
+func isTruthy(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+
+	if boolean, ok := value.(bool); ok {
+		return boolean
+	}
+
+	return true
+}